@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// digestTextLength is the most characters of a comment shown in an
+// --activity-digest entry.
+const digestTextLength = 80
+
+// activitySection merges comments and work log entries into the newest-first
+// list shown by --activity-digest, capped at config.activityDigest entries.
+// Entries whose date can't be parsed come last.
+func activitySection(item Item, config Config) section {
+	type entry struct {
+		date string
+		when time.Time
+		ok   bool
+		line string
+	}
+
+	var entries []entry
+	add := func(date, actor, action, text string) {
+		when, ok := parseJiraDate(date)
+		line := fmt.Sprintf("%s — **%s** %s", formatDate(date, config), actor, action)
+		if text = digestText(text, config); text != "" {
+			line += ": " + text
+		}
+		entries = append(entries, entry{date, when, ok, line})
+	}
+
+	for _, comment := range item.Comments.Comment {
+		if commentShown(comment, config) {
+			add(comment.Created, userName(comment.Author, config), config.label("commented"), comment.Value)
+		}
+	}
+	for _, wl := range item.Worklogs.Worklog {
+		date := wl.TimeStarted
+		if date == "" {
+			date = wl.Created
+		}
+		add(date, userName(wl.Author, config), strings.TrimSpace(config.label("logged_work")+" "+wl.TimeSpent), wl.Comment)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].ok != entries[j].ok {
+			return entries[i].ok
+		}
+		return entries[i].ok && entries[i].when.After(entries[j].when)
+	})
+	if len(entries) > config.activityDigest {
+		entries = entries[:config.activityDigest]
+	}
+
+	var body strings.Builder
+	for _, e := range entries {
+		body.WriteString("- " + e.line + "\n")
+	}
+
+	return section{id: "recent_activity", heading: config.label("recent_activity"), body: body.String()}
+}
+
+// digestText reduces a comment body to its first line, shortened to
+// digestTextLength characters.
+func digestText(s string, config Config) string {
+	text := renderBody(s, config)
+	text, _, _ = strings.Cut(text, "\n")
+	text = strings.TrimSpace(text)
+
+	if runes := []rune(text); len(runes) > digestTextLength {
+		text = strings.TrimSpace(string(runes[:digestTextLength])) + "…"
+	}
+	return text
+}
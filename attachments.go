@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// findAttachment looks for the file of att in an --attachments-dir. JIRA
+// full exports store attachments under PROJECT/KEY/ID (or PROJECT/BUCKET/KEY/ID
+// on larger instances); flatter layouts keyed by issue, id or name are also
+// accepted.
+func findAttachment(dir string, item Item, att Attachment) (string, bool) {
+	key := fileNamePart(item.Key.Value)
+	project, _, _ := strings.Cut(key, "-")
+
+	var candidates []string
+	for _, name := range []string{att.ID, att.Name} {
+		if name == "" || filepath.Base(name) != name {
+			continue
+		}
+		candidates = append(candidates,
+			filepath.Join(dir, project, key, name),
+			filepath.Join(dir, key, name),
+			filepath.Join(dir, name),
+		)
+	}
+	if att.ID != "" {
+		if matches, _ := filepath.Glob(filepath.Join(dir, project, "*", key, att.ID)); len(matches) > 0 {
+			candidates = append(candidates, matches[0])
+		}
+	}
+
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// copyAttachments copies the attachments of item found in --attachments-dir
+// into an attachments/KEY folder next to outputFile, and returns the copied
+// paths relative to outputFile keyed by attachment id, escaped for use as
+// link destinations. Missing files are skipped with a warning.
+func copyAttachments(item Item, outputFile string, config Config, state *runState) (map[string]string, error) {
+	key := fileNamePart(item.Key.Value)
+	linkDir := filepath.Join("attachments", key)
+	destDir := filepath.Join(filepath.Dir(outputFile), linkDir)
+
+	paths := make(map[string]string)
+	for _, att := range item.Attachments.Attachment {
+		src, ok := findAttachment(config.attachmentsDir, item, att)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Warning: attachment %q of %s not found in %s\n", att.Name, item.Key.Value, config.attachmentsDir)
+			continue
+		}
+
+		name := att.Name
+		if name == "" || filepath.Base(name) != name {
+			name = fileNamePart(att.ID)
+		}
+		dest := filepath.Join(destDir, name)
+
+		if _, err := os.Stat(dest); err == nil && !mayOverwrite(dest, config) {
+			return nil, fmt.Errorf("attachment file %s already exists (use -f to overwrite)", dest)
+		}
+		if err := os.MkdirAll(destDir, dirMode(config)); err != nil {
+			return nil, fmt.Errorf("failed to create attachments directory: %w", err)
+		}
+		if err := copyFile(src, dest, config); err != nil {
+			return nil, fmt.Errorf("failed to copy attachment: %w", err)
+		}
+		state.addOutput(config.inputFile, dest)
+
+		if config.verbose {
+			fmt.Fprintf(os.Stderr, "Copied %s\n", dest)
+		}
+		// Names often contain spaces or parentheses, which end a link
+		paths[att.ID] = "attachments/" + url.PathEscape(key) + "/" + url.PathEscape(name)
+	}
+
+	return paths, nil
+}
+
+// copyFile copies src to dest with the mode writeOutput would use.
+func copyFile(src, dest string, config Config) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	mode := config.fileMode
+	if mode == 0 {
+		mode = 0644
+	}
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if config.fileMode != 0 {
+		return os.Chmod(dest, config.fileMode)
+	}
+	return nil
+}
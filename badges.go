@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// categoryColors are the badge colors for each status category.
+var categoryColors = map[string]string{
+	"To Do":       "lightgrey",
+	"In Progress": "blue",
+	"Done":        "green",
+}
+
+// priorityColors are the badge colors for JIRA's standard priorities.
+var priorityColors = map[string]string{
+	"blocker":  "red",
+	"highest":  "red",
+	"critical": "red",
+	"high":     "orange",
+	"major":    "orange",
+	"medium":   "yellow",
+	"low":      "green",
+	"minor":    "green",
+	"lowest":   "brightgreen",
+	"trivial":  "brightgreen",
+}
+
+// badgeLine renders shields.io-style badges for the status, priority and
+// type of item, or "" when none of them is set.
+func badgeLine(item Item, config Config) string {
+	var badges []string
+	add := func(label, value, color string) {
+		if strings.TrimSpace(value) == "" {
+			return
+		}
+		if color == "" {
+			color = "lightgrey"
+		}
+		src := strings.TrimSuffix(config.badgeURL, "/") + "/" + badgePart(label) + "-" + badgePart(value) + "-" + color
+		badges = append(badges, markdownImage(label, src))
+	}
+
+	add(strings.ToLower(config.label("status")), item.Status.Value, categoryColors[statusCategory(item)])
+	add(strings.ToLower(config.label("priority")), item.Priority.Value, priorityColors[strings.ToLower(strings.TrimSpace(item.Priority.Value))])
+	add(strings.ToLower(config.label("type")), item.Type.Value, "blue")
+
+	return strings.Join(badges, " ")
+}
+
+// badgePart escapes text for one dash-separated part of a shields.io badge
+// path, where "-" and "_" are doubled and spaces become "_".
+func badgePart(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.ReplaceAll(s, "-", "--")
+	s = strings.ReplaceAll(s, "_", "__")
+	s = strings.ReplaceAll(s, " ", "_")
+	return url.PathEscape(s)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BaselineIssue is the subset of a previously exported issue that
+// --baseline compares against.
+type BaselineIssue struct {
+	Status      string
+	Assignee    string
+	Description string
+	Comments    map[string]bool // keyed by comment creation timestamp
+}
+
+// loadBaseline reads a prior XML export or a Markdown file generated by this
+// tool and returns the issues it contains keyed by issue key.
+func loadBaseline(path string, config Config) (map[string]BaselineIssue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".xml") {
+		return parseBaselineXML(data, config)
+	}
+	return parseBaselineMarkdown(string(data), config), nil
+}
+
+func parseBaselineXML(data []byte, config Config) (map[string]BaselineIssue, error) {
+	rss, err := parseRSS(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse baseline XML: %w", err)
+	}
+
+	config.channelLink = rss.Channel.Link
+
+	issues := make(map[string]BaselineIssue)
+	for _, item := range rss.Channel.Items {
+		description, _ := renderDescription(item, config)
+		if !config.keepTrailingWS {
+			description = trimTrailingWhitespace(description)
+		}
+		issue := BaselineIssue{
+			Status:      item.Status.Value,
+			Assignee:    item.Assignee.Value,
+			Description: description,
+			Comments:    make(map[string]bool),
+		}
+		for _, comment := range item.Comments.Comment {
+			issue.Comments[comment.Created] = true
+		}
+		issues[item.Key.Value] = issue
+	}
+
+	return issues, nil
+}
+
+// parseBaselineMarkdown recovers baseline fields from the headings and
+// Overview bullets written by generateMarkdown. Lines in fenced code blocks
+// are body text, even when they look like headings.
+func parseBaselineMarkdown(md string, config Config) map[string]BaselineIssue {
+	issues := make(map[string]BaselineIssue)
+
+	var key, section, fence string
+	var issue BaselineIssue
+	var details []string
+
+	statusPrefix := "- **" + config.label("status") + ":** "
+	assigneePrefix := "- **" + config.label("assignee") + ":** "
+
+	flush := func() {
+		if key == "" {
+			return
+		}
+		issue.Description = strings.TrimSpace(strings.Join(details, "\n"))
+		issues[key] = issue
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(md))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		marker := fenceMarker(strings.TrimLeft(line, " "))
+		inFence := fence != "" || marker != ""
+		if marker != "" {
+			if fence == "" {
+				fence = marker
+			} else if marker[0] == fence[0] && len(marker) >= len(fence) {
+				fence = ""
+			}
+		}
+
+		switch {
+		case inFence:
+		case strings.HasPrefix(line, "# "):
+			flush()
+			key, _, _ = strings.Cut(strings.TrimPrefix(line, "# "), ":")
+			key = strings.TrimSpace(key)
+			section = ""
+			issue = BaselineIssue{Comments: make(map[string]bool)}
+			details = nil
+			continue
+		case strings.HasPrefix(line, "## "):
+			section = strings.TrimPrefix(line, "## ")
+			continue
+		}
+
+		switch section {
+		case config.label("overview"):
+			if inFence {
+				break
+			}
+			if v, ok := strings.CutPrefix(line, statusPrefix); ok {
+				issue.Status = plainValue(v)
+			} else if v, ok := strings.CutPrefix(line, assigneePrefix); ok {
+				issue.Assignee = plainValue(v)
+			}
+		case config.label("details"):
+			details = append(details, line)
+		case config.label("comments"):
+			if v, ok := strings.CutPrefix(line, "### "); ok && !inFence {
+				// Headings are "author — date", or just the date
+				if i := strings.LastIndex(v, " — "); i != -1 {
+					v = v[i+len(" — "):]
+				}
+				issue.Comments[v] = true
+			}
+		}
+	}
+	flush()
+
+	return issues
+}
+
+// plainValue strips what --avatars, --icons and --status-emoji put before an
+// Overview value: an avatar image, then an emoji or :shortcode: icon.
+func plainValue(v string) string {
+	if strings.HasPrefix(v, "![") {
+		if end := strings.Index(v, ") "); end != -1 {
+			v = v[end+len(") "):]
+		}
+	}
+	if icon, rest, ok := strings.Cut(v, " "); ok {
+		r, _ := utf8.DecodeRuneInString(icon)
+		if unicode.IsSymbol(r) || (len(icon) > 2 && strings.HasPrefix(icon, ":") && strings.HasSuffix(icon, ":")) {
+			v = rest
+		}
+	}
+	return v
+}
+
+// sameStatus reports whether a baseline status matches the status of item,
+// allowing for the category --status-category adds after it.
+func sameStatus(base string, item Item) bool {
+	if category := statusCategory(item); category != "" {
+		base = strings.TrimSuffix(base, " ("+category+")")
+	}
+	return base == item.Status.Value
+}
+
+// generateChanges renders a compact "What Changed" note for item relative to
+// its baseline.
+func generateChanges(item Item, base BaselineIssue, config Config) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "# %s: %s\n\n", item.Key.Value, itemSummary(item, config))
+	if item.Link != "" {
+		fmt.Fprintf(&sb, "**%s:** [%s](%s)\n\n", config.label("link"), item.Link, item.Link)
+	}
+
+	fmt.Fprintf(&sb, "## %s\n\n", config.label("what_changed"))
+
+	changed := false
+	if !sameStatus(base.Status, item) {
+		fmt.Fprintf(&sb, "- **%s:** %s → %s\n", config.label("status"), base.Status, item.Status.Value)
+		changed = true
+	}
+	if item.Assignee.Value != base.Assignee {
+		fmt.Fprintf(&sb, "- **%s:** %s → %s\n", config.label("assignee"), base.Assignee, item.Assignee.Value)
+		changed = true
+	}
+
+	description, _ := renderDescription(item, config)
+	// The baseline file was written with trailing whitespace trimmed
+	if !config.keepTrailingWS {
+		description = trimTrailingWhitespace(description)
+	}
+	if description != base.Description {
+		if changed {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "### %s\n\n", config.label("description"))
+		sb.WriteString(description)
+		sb.WriteString("\n")
+		changed = true
+	}
+
+	var newComments []Comment
+	for _, comment := range item.Comments.Comment {
+		// A Markdown baseline records the date as it was displayed
+		if !base.Comments[comment.Created] && !base.Comments[formatDate(comment.Created, config)] {
+			newComments = append(newComments, comment)
+		}
+	}
+	if len(newComments) > 0 {
+		if changed {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "### %s\n\n", config.label("new_comments"))
+		for _, comment := range newComments {
+			fmt.Fprintf(&sb, "#### %s\n\n", commentHeading(comment, config))
+			sb.WriteString(renderBody(comment.Value, config))
+			sb.WriteString("\n\n")
+		}
+		changed = true
+	}
+
+	if !changed {
+		fmt.Fprintf(&sb, "_%s_\n", config.label("no_changes"))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestParseBaselineMarkdown(t *testing.T) {
+	tests := []struct {
+		name        string
+		md          string
+		status      string
+		assignee    string
+		description string
+	}{
+		{
+			name:        "plain",
+			md:          "# AI-1: Title\n\n## Overview\n\n- **Status:** In Progress\n- **Assignee:** jsmith\n\n## Details\n\nText\n",
+			status:      "In Progress",
+			assignee:    "jsmith",
+			description: "Text",
+		},
+		{
+			name:        "headings in fenced code",
+			md:          "# AI-1: Title\n\n## Details\n\nRun:\n\n```sh\n# comment\n## not a section\nls\n```\n\nafter\n\n## Comments\n",
+			description: "Run:\n\n```sh\n# comment\n## not a section\nls\n```\n\nafter",
+		},
+		{
+			name:   "status emoji",
+			md:     "# AI-1: Title\n\n## Overview\n\n- **Status:** 🔵 In Progress\n",
+			status: "In Progress",
+		},
+		{
+			name:   "icon shortcode",
+			md:     "# AI-1: Title\n\n## Overview\n\n- **Status:** :construction: In Progress\n",
+			status: "In Progress",
+		},
+		{
+			name:     "avatar",
+			md:       "# AI-1: Title\n\n## Overview\n\n- **Assignee:** ![](https://x.test/a.png) jsmith\n",
+			assignee: "jsmith",
+		},
+	}
+
+	config := testConfig(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issue, ok := parseBaselineMarkdown(tt.md, config)["AI-1"]
+			if !ok {
+				t.Fatal("AI-1 not found")
+			}
+			if issue.Status != tt.status || issue.Assignee != tt.assignee || issue.Description != tt.description {
+				t.Errorf("got status %q, assignee %q, description %q; want %q, %q, %q",
+					issue.Status, issue.Assignee, issue.Description, tt.status, tt.assignee, tt.description)
+			}
+		})
+	}
+}
+
+func TestSameStatus(t *testing.T) {
+	item := Item{Status: Status{Value: "In Review", Category: "In Progress"}}
+	tests := []struct {
+		base string
+		want bool
+	}{
+		{"In Review", true},
+		{"In Review (In Progress)", true},
+		{"In Review (Done)", false},
+		{"Done", false},
+	}
+
+	for _, tt := range tests {
+		if got := sameStatus(tt.base, item); got != tt.want {
+			t.Errorf("sameStatus(%q) = %v, want %v", tt.base, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// duplicateWindow is how far apart two identical comments by the same author
+// may be posted and still be treated as a sync duplicate by --dedupe-comments.
+const duplicateWindow = 5 * time.Minute
+
+// dedupeComments drops comments whose text matches an earlier comment by the
+// same author posted within duplicateWindow, and returns how many it dropped.
+// Text is compared with whitespace normalized. If either date can't be
+// parsed, the dates must match exactly.
+func dedupeComments(comments []Comment) ([]Comment, int) {
+	var kept []Comment
+	removed := 0
+
+	for _, c := range comments {
+		duplicate := false
+		for _, k := range kept {
+			if k.Author == c.Author && normalizeText(k.Value) == normalizeText(c.Value) && sameMoment(k.Created, c.Created) {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			removed++
+			continue
+		}
+		kept = append(kept, c)
+	}
+
+	return kept, removed
+}
+
+// normalizeText collapses all whitespace runs in s to single spaces.
+func normalizeText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// sameMoment reports whether two export dates are within duplicateWindow of
+// each other.
+func sameMoment(a, b string) bool {
+	ta, okA := parseJiraDate(a)
+	tb, okB := parseJiraDate(b)
+	if !okA || !okB {
+		return strings.TrimSpace(a) == strings.TrimSpace(b)
+	}
+	d := ta.Sub(tb)
+	return d > -duplicateWindow && d < duplicateWindow
+}
+
+// commentFileName returns the per-comment file name used by --comments-dir.
+// Comments without an id fall back to their position in the thread.
+func commentFileName(key string, comment Comment, index int) string {
+	id := comment.ID
+	if id == "" {
+		id = fmt.Sprint(index + 1)
+	}
+	return fmt.Sprintf("%s-comment-%s.md", fileNamePart(key), fileNamePart(id))
+}
+
+// writeCommentFiles writes each comment of item to its own Markdown file in
+// config.commentsDir, with the author and date as front matter.
+func writeCommentFiles(item Item, config Config, state *runState) error {
+	if err := os.MkdirAll(config.commentsDir, dirMode(config)); err != nil {
+		return fmt.Errorf("failed to create comments directory: %w", err)
+	}
+
+	for i, comment := range item.Comments.Comment {
+		path := filepath.Join(config.commentsDir, commentFileName(item.Key.Value, comment, i))
+
+		if _, err := os.Stat(path); err == nil && !mayOverwrite(path, config) {
+			return fmt.Errorf("comment file %s already exists (use -f to overwrite)", path)
+		}
+
+		var sb strings.Builder
+		sb.WriteString("---\n")
+		fmt.Fprintf(&sb, "key: %s\n", yamlString(item.Key.Value))
+		fmt.Fprintf(&sb, "comment_id: %s\n", yamlString(comment.ID))
+		fmt.Fprintf(&sb, "author: %s\n", yamlString(comment.Author))
+		fmt.Fprintf(&sb, "date: %s\n", yamlString(formatDate(comment.Created, config)))
+		sb.WriteString("---\n\n")
+		sb.WriteString(renderBody(comment.Value, config))
+		sb.WriteString("\n")
+
+		md, _ := redact(sb.String(), config.redactions)
+		if !config.keepTrailingWS {
+			md = trimTrailingWhitespace(md)
+		}
+
+		if err := writeOutput(path, encodeOutput(md, config), config); err != nil {
+			return fmt.Errorf("failed to write comment file: %w", err)
+		}
+		state.addOutput(config.inputFile, path)
+
+		if config.verbose {
+			fmt.Fprintf(os.Stderr, "Created %s\n", path)
+		}
+	}
+
+	return nil
+}
+
+// yamlString returns s as a YAML scalar, double-quoting it when it is empty
+// or contains characters that YAML would otherwise interpret.
+func yamlString(s string) string {
+	if s != "" && !strings.ContainsAny(s, ":#'\"{}[],&*!|>%@`\\\n\t") &&
+		strings.TrimSpace(s) == s && !strings.HasPrefix(s, "-") && !strings.HasPrefix(s, "?") &&
+		!yamlAmbiguous(s) {
+		return s
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+
+	return sb.String()
+}
+
+// yamlAmbiguous reports whether a plain scalar would be read back as a
+// number, boolean or null rather than a string.
+func yamlAmbiguous(s string) bool {
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		return true
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+)
+
+// csvColumns are the header row of the --csv summary.
+var csvColumns = []string{"key", "summary", "type", "status", "priority", "assignee", "reporter", "created", "updated"}
+
+// writeCSV writes one row per converted item, in run order, quoted per
+// RFC 4180.
+func writeCSV(path string, entries []indexEntry, config Config) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.UseCRLF = true
+
+	if err := w.Write(csvColumns); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	for _, entry := range entries {
+		item := entry.item
+		record := []string{
+			item.Key.Value,
+			itemSummary(item, config),
+			item.Type.Value,
+			item.Status.Value,
+			item.Priority.Value,
+			item.Assignee.Value,
+			item.Reporter.Value,
+			item.Created,
+			item.Updated,
+		}
+		// The documents are redacted, so the summary of them must be too
+		for i, cell := range record {
+			record[i] = redacted(cell, config)
+		}
+		if err := w.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	if err := writeOutput(path, buf.Bytes(), config); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// structuredFieldTypes are custom field type key prefixes of plugins known to
+// export their values as nested XML rather than text.
+var structuredFieldTypes = []string{
+	"com.tempoplugin.tempo-accounts",
+	"com.tempoplugin.tempo-teams",
+	"com.riadalabs.jira.plugins.insight",
+	"com.atlassian.jira.plugins.cmdb",
+}
+
+// displayNames are the attributes and child elements that carry the
+// human-readable part of a structured value, in order of preference.
+var displayNames = []string{"name", "label", "displayName", "value", "key"}
+
+// renderDirectives are the ways --field-render-map can render a custom field.
+var renderDirectives = map[string]bool{
+	"text":     true,
+	"markdown": true,
+	"link":     true,
+	"date":     true,
+	"user":     true,
+	"bullets":  true,
+	"table":    true,
+}
+
+// loadFieldRenderMap reads a JSON object mapping custom field names, ids or
+// type keys to render directives.
+func loadFieldRenderMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read field render map: %w", err)
+	}
+
+	var renders map[string]string
+	if err := json.Unmarshal(data, &renders); err != nil {
+		return nil, fmt.Errorf("failed to parse field render map: %w", err)
+	}
+
+	for field, directive := range renders {
+		if !renderDirectives[directive] {
+			return nil, fmt.Errorf("invalid render directive %q for field %q (expected text, markdown, link, date, user, bullets or table)", directive, field)
+		}
+	}
+
+	return renders, nil
+}
+
+// fieldDirective returns the --field-render-map directive for cf, looked up
+// by field name, then id, then type key, or "" if the field isn't mapped.
+func fieldDirective(cf CustomField, config Config) string {
+	for _, name := range []string{cf.CustomFieldName, cf.ID, cf.Key} {
+		if directive, ok := config.fieldRenders[name]; ok && name != "" {
+			return directive
+		}
+	}
+	return ""
+}
+
+// renderCustomField returns the value of cf as shown in the Custom Fields
+// section, or "" if it has none. A --field-render-map directive replaces the
+// usual heuristics.
+func renderCustomField(cf CustomField, config Config) string {
+	directive := fieldDirective(cf, config)
+	if directive == "" || directive == "date" {
+		return joinValues(customFieldValues(cf), config)
+	}
+
+	if directive == "table" {
+		return customFieldTable(cf)
+	}
+
+	var values []string
+	for _, v := range cf.CustomFieldValues.CustomFieldValue {
+		value := strings.TrimSpace(v.Value)
+		switch directive {
+		case "markdown":
+			value = renderBody(v.Value, config)
+		case "link":
+			if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+				value = fmt.Sprintf("[%s](%s)", value, value)
+			}
+		case "user":
+			// User pickers from plugins may export nested XML; keep just the names
+			if names, _ := structuredNames(strings.TrimSpace(v.Inner)); len(names) > 0 {
+				value = strings.Join(names, ", ")
+			} else {
+				value = customFieldValue(cf, v)
+			}
+		}
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+
+	if directive == "bullets" && len(values) > 0 {
+		return "- " + strings.Join(values, "\n- ")
+	}
+	return joinValues(values, config)
+}
+
+// customFieldTable renders the values of cf as a Markdown table. Nested XML
+// values get a column per attribute or child element; plain values are
+// listed in a single column headed by the field name.
+func customFieldTable(cf CustomField) string {
+	var rows [][]string
+	var columns []string
+	structured := false
+
+	for _, v := range cf.CustomFieldValues.CustomFieldValue {
+		records, cols, nested := structuredRecords(strings.TrimSpace(v.Inner))
+		if !nested {
+			if value := strings.TrimSpace(v.Value); value != "" {
+				rows = append(rows, []string{value})
+			}
+			continue
+		}
+		structured = true
+		for _, col := range cols {
+			if !containsString(columns, col) {
+				columns = append(columns, col)
+			}
+		}
+		for _, record := range records {
+			var row []string
+			for _, col := range columns {
+				row = append(row, record[col])
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	if len(rows) == 0 {
+		return ""
+	}
+	if !structured {
+		columns = []string{cf.CustomFieldName}
+	}
+
+	var sb strings.Builder
+	for i, row := range append([][]string{columns}, rows...) {
+		cells := make([]string, len(row))
+		for j, cell := range row {
+			cells[j] = tableCell(cell)
+		}
+		writeTableRow(&sb, cells, len(columns))
+		if i == 0 {
+			sb.WriteString("|" + strings.Repeat(" --- |", len(columns)) + "\n")
+		}
+	}
+
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// fieldSelected reports whether the custom field passes --include-fields and
+// --exclude-fields. Names match case-insensitively, and an excluded field
+// stays out even if it is also included.
+func fieldSelected(cf CustomField, config Config) bool {
+	name := strings.TrimSpace(cf.CustomFieldName)
+	matches := func(list []string) bool {
+		for _, v := range list {
+			if strings.EqualFold(strings.TrimSpace(v), name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if matches(config.excludeFields) {
+		return false
+	}
+	return len(config.includeFields) == 0 || matches(config.includeFields)
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// customFieldValues returns the non-empty display values of cf.
+func customFieldValues(cf CustomField) []string {
+	var values []string
+	for _, v := range cf.CustomFieldValues.CustomFieldValue {
+		if s := customFieldValue(cf, v); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// customFieldValue returns the display value of v. Plain values are returned
+// as is. Nested XML from a recognized plugin is reduced to the names of its
+// objects; any other nested XML is rendered as a code block.
+func customFieldValue(cf CustomField, v CustomFieldValue) string {
+	inner := strings.TrimSpace(v.Inner)
+	names, nested := structuredNames(inner)
+	if !nested {
+		return v.Value
+	}
+
+	if isStructuredFieldType(cf.Key) && len(names) > 0 {
+		return strings.Join(names, ", ")
+	}
+
+	return renderFence(inner, "xml")
+}
+
+// isStructuredFieldType reports whether key belongs to a plugin in
+// structuredFieldTypes.
+func isStructuredFieldType(key string) bool {
+	for _, prefix := range structuredFieldTypes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// structuredNames scans the inner XML of a value and returns the display name
+// of each top-level element, and whether there were any elements at all. An
+// element's name is taken from its first attribute or direct child element
+// listed in displayNames.
+func structuredNames(inner string) ([]string, bool) {
+	records, _, nested := structuredRecords(inner)
+
+	var names []string
+	for _, record := range records {
+		for _, name := range displayNames {
+			if value := record[name]; value != "" {
+				names = append(names, value)
+				break
+			}
+		}
+	}
+
+	return names, nested
+}
+
+// structuredRecords scans the inner XML of a value and returns the attributes
+// and direct child elements of each top-level element, the names of those
+// properties in order of first appearance, and whether there were any
+// elements at all. Attributes win over child elements of the same name.
+func structuredRecords(inner string) ([]map[string]string, []string, bool) {
+	if !strings.Contains(inner, "<") {
+		return nil, nil, false
+	}
+
+	d := xml.NewDecoder(strings.NewReader(inner))
+	d.Strict = false
+
+	var records []map[string]string
+	var columns []string
+	seen := map[string]bool{}
+	nested := false
+	depth := 0
+	record := map[string]string{}
+	child := ""
+	var text strings.Builder
+
+	set := func(name, value string) {
+		if _, ok := record[name]; ok {
+			return
+		}
+		record[name] = value
+		if !seen[name] {
+			seen[name] = true
+			columns = append(columns, name)
+		}
+	}
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Not well-formed; show it raw rather than guess
+			return nil, nil, nested
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			nested = true
+			depth++
+			switch depth {
+			case 1:
+				record = map[string]string{}
+				for _, attr := range t.Attr {
+					set(attr.Name.Local, attr.Value)
+				}
+			case 2:
+				child = t.Name.Local
+				text.Reset()
+			}
+		case xml.CharData:
+			if depth == 2 {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			switch depth {
+			case 2:
+				set(child, strings.TrimSpace(text.String()))
+			case 1:
+				records = append(records, record)
+			}
+			depth--
+		}
+	}
+
+	return records, columns, nested
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// jiraDateLayouts are the date formats seen in JIRA exports, most common
+// first.
+var jiraDateLayouts = []string{
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2006-01-02T15:04:05.000-0700",
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"02/Jan/06 3:04 PM",
+	"02/Jan/06",
+}
+
+// dateFormatPresets are the named layouts accepted by --date-format besides
+// a Go reference-time layout.
+var dateFormatPresets = map[string]string{
+	"iso":       time.RFC3339,
+	"date-only": "2006-01-02",
+	"datetime":  "2006-01-02 15:04",
+	"rfc1123":   time.RFC1123Z,
+}
+
+// dateLayout returns the Go layout for a --date-format value, which is either
+// the name of a preset or a layout itself.
+func dateLayout(format string) string {
+	if layout, ok := dateFormatPresets[strings.ToLower(format)]; ok {
+		return layout
+	}
+	return format
+}
+
+// formatDate reformats a date from an export with --date-format. Dates that
+// can't be parsed, and every date without the flag, are returned as written.
+func formatDate(s string, config Config) string {
+	if config.dateLayout == "" {
+		return s
+	}
+	t, ok := parseJiraDate(s)
+	if !ok {
+		return s
+	}
+	return t.Format(config.dateLayout)
+}
+
+// parseJiraDate parses a date string from an export, reporting false if it
+// matches none of the known layouts.
+func parseJiraDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range jiraDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// isDateField reports whether a custom field holds a date. Fields are
+// recognized by name, as in the Dates section, unless --field-render-map
+// says otherwise.
+func isDateField(cf CustomField, config Config) bool {
+	if directive := fieldDirective(cf, config); directive != "" {
+		return directive == "date"
+	}
+	return strings.Contains(strings.ToLower(cf.CustomFieldName), "date")
+}
+
+// timelineSection collects the built-in and custom date fields of item into
+// a chronologically sorted section. Dates that can't be parsed are listed
+// last, in their original order.
+func timelineSection(item Item, config Config) section {
+	type entry struct {
+		name  string
+		value string
+		when  time.Time
+		ok    bool
+	}
+
+	var entries []entry
+	add := func(name, value string) {
+		if strings.TrimSpace(value) == "" {
+			return
+		}
+		when, ok := parseJiraDate(value)
+		entries = append(entries, entry{name, value, when, ok})
+	}
+
+	add(config.label("created"), item.Created)
+	add(config.label("updated"), item.Updated)
+	add(config.label("resolved"), item.Resolved)
+	add(config.label("due"), item.Due)
+	if config.details {
+		for _, cf := range item.CustomFields.CustomField {
+			if isDateField(cf, config) && len(cf.CustomFieldValues.CustomFieldValue) > 0 {
+				add(cf.CustomFieldName, cf.CustomFieldValues.CustomFieldValue[0].Value)
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].ok != entries[j].ok {
+			return entries[i].ok
+		}
+		return entries[i].ok && entries[i].when.Before(entries[j].when)
+	})
+
+	var body strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&body, "- %s: %s", formatDate(e.value, config), e.name)
+		if !e.ok {
+			fmt.Fprintf(&body, " _(%s)_", config.label("unparsed_date"))
+		}
+		body.WriteString("\n")
+	}
+
+	return section{id: "timeline", heading: config.label("timeline"), body: body.String()}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	pflag "github.com/spf13/pflag"
+)
+
+// doctorSample is a small export exercising each of the HTML transforms.
+const doctorSample = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="0.92">
+<channel>
+<title>Doctor</title>
+<link>https://jira.example.com</link>
+<item>
+<title>[DOC-1] Self test</title>
+<link>https://jira.example.com/browse/DOC-1</link>
+<key id="1">DOC-1</key>
+<summary>Self test</summary>
+<type id="1">Bug</type>
+<priority id="2">High</priority>
+<status id="3">Open</status>
+<assignee>doctor</assignee>
+<reporter>doctor</reporter>
+<created>Mon, 3 Jun 2024 10:00:00 +0000</created>
+<updated>Tue, 4 Jun 2024 10:00:00 +0000</updated>
+<description>&lt;p&gt;Fish &amp;amp; chips &lt;b&gt;today&lt;/b&gt;&lt;/p&gt;&lt;ul&gt;&lt;li&gt;first&lt;/li&gt;&lt;/ul&gt;&lt;p&gt;&lt;a href="https://example.com/docs"&gt;docs&lt;/a&gt; &lt;img src="https://example.com/a.png" /&gt;&lt;/p&gt;&lt;pre&gt;x &amp;lt; 1&lt;/pre&gt;</description>
+<comments>
+<comment id="10" author="doctor" created="Mon, 3 Jun 2024 11:00:00 +0000">&lt;p&gt;Looks fine&lt;/p&gt;</comment>
+</comments>
+<attachments>
+<attachment id="20" name="log.txt" size="12" author="doctor" created="Mon, 3 Jun 2024 12:00:00 +0000"/>
+</attachments>
+</item>
+</channel>
+</rss>
+`
+
+// doctorChecks are the fragments the sample must produce, one per transform.
+var doctorChecks = []struct {
+	name string
+	want string
+}{
+	{"title", "# DOC-1: Self test"},
+	{"entities", "Fish & chips"},
+	{"bold", "**today**"},
+	{"lists", "- first"},
+	{"links", "[docs](https://example.com/docs)"},
+	{"images", "](https://example.com/a.png)"},
+	{"preformatted", "```\nx < 1\n```"},
+	{"comments", "Looks fine"},
+	{"attachments", "[log.txt](https://jira.example.com/rest/api/3/attachment/content/20)"},
+}
+
+// runDoctor converts the built-in sample with default options, reports each
+// self-check and some environment details to w, and returns whether every
+// check passed.
+func runDoctor(w io.Writer) bool {
+	ok := true
+	report := func(pass bool, name, detail string) {
+		status := "ok  "
+		if !pass {
+			status = "FAIL"
+			ok = false
+		}
+		if detail != "" {
+			name += ": " + detail
+		}
+		fmt.Fprintf(w, "%s %s\n", status, name)
+	}
+
+	fmt.Fprintf(w, "converttomd-jira %s (%s, %s/%s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(w, "locale: %s\n", detectLocale())
+
+	if f, err := os.CreateTemp("", "converttomd-jira-doctor-*"); err != nil {
+		report(false, "temp dir writable", err.Error())
+	} else {
+		f.Close()
+		os.Remove(f.Name())
+		report(true, "temp dir writable", os.TempDir())
+	}
+
+	// Start from the flag defaults, as a run without options would
+	config := Config{}
+	defineFlags(pflag.NewFlagSet("doctor", pflag.ContinueOnError), &config)
+	if err := prepareConfig(&config); err != nil {
+		report(false, "default options", err.Error())
+		return false
+	}
+
+	rss, err := parseRSS([]byte(doctorSample), config)
+	if err != nil || len(rss.Channel.Items) == 0 {
+		report(false, "parse sample", fmt.Sprint(err))
+		return false
+	}
+	report(true, "parse sample", "")
+	config.channelLink = rss.Channel.Link
+
+	md, err := renderItem(rss.Channel.Items[0], rss.Channel.Link, config)
+	if err != nil {
+		report(false, "render sample", err.Error())
+		return false
+	}
+	for _, check := range doctorChecks {
+		report(strings.Contains(md, check.want), check.name, "")
+	}
+
+	warnings := lintMarkdown(md)
+	report(len(warnings) == 0, "lint", strings.Join(warnings, "; "))
+
+	return ok
+}
+
+// detectLocale returns the locale from the environment, in the order the C
+// library consults it.
+func detectLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return "(not set)"
+}
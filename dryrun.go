@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// reportDryRun prints what a --dry-run would have done with a document
+// converted from source: the output path and whether it would be created,
+// overwritten or appended to. With --verbose the size of the output is
+// added; a negative size is left out.
+func reportDryRun(source, path string, size int, config Config) {
+	action := "stdout"
+	if path != "-" {
+		action = dryRunAction(path, config)
+	}
+
+	if config.verbose && size >= 0 {
+		fmt.Printf("%s -> %s (%s, %d bytes)\n", source, path, action, size)
+	} else {
+		fmt.Printf("%s -> %s (%s)\n", source, path, action)
+	}
+}
+
+// dryRunAction describes what writing path would do to it.
+func dryRunAction(path string, config Config) string {
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		return "create"
+	case config.append && info.Size() > 0:
+		return "append"
+	case config.append || config.force || config.assumeYes:
+		return "overwrite"
+	default:
+		return "exists, needs -f to overwrite"
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// utf8BOM is the byte order mark some tools put at the start of an export.
+var utf8BOM = []byte("\xEF\xBB\xBF")
+
+// parseRSS decodes an export into an RSS document. A leading byte order mark
+// is skipped. With --input-encoding the bytes are transcoded to UTF-8 before
+// parsing; otherwise any non-UTF-8 encoding named in the XML declaration is
+// honoured.
+func parseRSS(data []byte, config Config) (RSS, error) {
+	var rss RSS
+
+	body := bytes.TrimPrefix(data, utf8BOM)
+	decoder, err := newXMLDecoder(bytes.NewReader(body), config)
+	if err != nil {
+		return rss, err
+	}
+
+	if err := decoder.Decode(&rss); err != nil {
+		return rss, xmlError(err, decoder, len(data)-len(body))
+	}
+
+	if config.normalizeKeys {
+		normalizeKeys(&rss)
+	}
+
+	return rss, nil
+}
+
+// parseDocuments decodes an input that may hold several RSS documents
+// concatenated back to back, as produced by cat-ing single-item exports
+// together. Each document is parsed on its own.
+func parseDocuments(data []byte, config Config) ([]RSS, error) {
+	chunks := splitDocuments(data)
+
+	docs := make([]RSS, 0, len(chunks))
+	for i, chunk := range chunks {
+		rss, err := parseRSS(chunk, config)
+		if err != nil {
+			if len(chunks) > 1 {
+				return nil, fmt.Errorf("document %d of %d: %w", i+1, len(chunks), err)
+			}
+			return nil, err
+		}
+		docs = append(docs, rss)
+	}
+
+	return docs, nil
+}
+
+// splitDocuments splits data after each </rss> that is followed by another
+// XML declaration or <rss> root.
+func splitDocuments(data []byte) [][]byte {
+	var chunks [][]byte
+	for {
+		end := bytes.Index(data, []byte("</rss>"))
+		if end < 0 {
+			break
+		}
+		end += len("</rss>")
+
+		next := -1
+		for _, marker := range []string{"<?xml", "<rss"} {
+			if i := bytes.Index(data[end:], []byte(marker)); i >= 0 && (next < 0 || i < next) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+
+		chunks = append(chunks, data[:end+next])
+		data = data[end+next:]
+	}
+	return append(chunks, data)
+}
+
+// documentItems flattens the items of docs into one list, alongside the
+// channel link of the document each came from.
+func documentItems(docs []RSS) ([]Item, []string) {
+	var items []Item
+	var links []string
+	for _, rss := range docs {
+		for _, item := range rss.Channel.Items {
+			items = append(items, item)
+			links = append(links, rss.Channel.Link)
+		}
+	}
+	return items, links
+}
+
+// newXMLDecoder returns a decoder for an export read from r, set up for the
+// input encoding as described for parseRSS.
+func newXMLDecoder(r io.Reader, config Config) (*xml.Decoder, error) {
+	if config.inputEncoding != "" {
+		enc, err := htmlindex.Get(config.inputEncoding)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported input encoding %q", config.inputEncoding)
+		}
+		r = enc.NewDecoder().Reader(r)
+	}
+
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		if config.inputEncoding != "" {
+			// Already transcoded; ignore what the declaration claims
+			return input, nil
+		}
+		enc, err := htmlindex.Get(label)
+		if err != nil {
+			// Some exports declare an encoding that doesn't exist; their
+			// content is nearly always UTF-8 regardless
+			fmt.Fprintf(os.Stderr, "Warning: unknown encoding %q in XML declaration, reading the input as UTF-8 (use --input-encoding to override)\n", label)
+			return input, nil
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+
+	return decoder, nil
+}
+
+// xmlError adds where in the input decoding stopped to err, as a line,
+// column and byte offset. skipped is the number of bytes dropped from the
+// start of the input before decoding, such as a byte order mark. Offsets
+// count decoded bytes, which differ from the file's with --input-encoding.
+func xmlError(err error, decoder *xml.Decoder, skipped int) error {
+	line, column := decoder.InputPos()
+	offset := decoder.InputOffset() + int64(skipped)
+
+	var syntaxErr *xml.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		// The message already names the line
+		return fmt.Errorf("%w (column %d, byte offset %d)", err, column, offset)
+	}
+	return fmt.Errorf("%w (line %d, column %d, byte offset %d)", err, line, column, offset)
+}
+
+// skipBOM returns a reader for r without a leading byte order mark, and the
+// number of bytes it skipped.
+func skipBOM(r io.Reader) (io.Reader, int) {
+	br := bufio.NewReader(r)
+	if prefix, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		br.Discard(len(utf8BOM))
+		return br, len(utf8BOM)
+	}
+	return br, 0
+}
+
+// streamItems decodes the items of an export one at a time, calling fn with
+// each item and the channel's link, so memory use doesn't grow with the size
+// of the export.
+func streamItems(r io.Reader, config Config, fn func(item Item, channelLink string) error) error {
+	r, skipped := skipBOM(r)
+	decoder, err := newXMLDecoder(r, config)
+	if err != nil {
+		return err
+	}
+
+	channelLink := ""
+	var path []string
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return xmlError(err, decoder, skipped)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			inChannel := len(path) == 2 && path[1] == "channel"
+			switch {
+			case inChannel && t.Name.Local == "link":
+				if err := decoder.DecodeElement(&channelLink, &t); err != nil {
+					return xmlError(err, decoder, skipped)
+				}
+			case inChannel && t.Name.Local == "item":
+				var item Item
+				if err := decoder.DecodeElement(&item, &t); err != nil {
+					return xmlError(err, decoder, skipped)
+				}
+				if config.normalizeKeys {
+					normalizeItemKeys(&item)
+				}
+				if err := fn(item, channelLink); err != nil {
+					return err
+				}
+			default:
+				path = append(path, t.Name.Local)
+			}
+		case xml.EndElement:
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		}
+	}
+}
@@ -2,4 +2,8 @@ module github.com/jondavis/converttomd-jira
 
 go 1.21
 
-require github.com/spf13/pflag v1.0.10 // indirect
+require (
+	github.com/spf13/pflag v1.0.10
+	github.com/yuin/goldmark v1.7.4
+	golang.org/x/text v0.14.0
+)
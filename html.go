@@ -0,0 +1,520 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var listTagPattern = regexp.MustCompile(`<(/?)(ul|ol|li)(\s[^>]*)?>`)
+
+var inlineTagPattern = regexp.MustCompile(`<(/?)(b|strong|em|i|del|s|strike|u|ins)(\s[^>]*)?>`)
+
+var htmlHeadingPattern = regexp.MustCompile(`(?is)<h([1-6])(?:\s[^>]*)?>(.*?)</h[1-6]>`)
+
+var anchorPattern = regexp.MustCompile(`(?i)<a name="[^"]*"\s*>\s*</a>`)
+
+var inlineCodePattern = regexp.MustCompile(`(?s)<code(?:\s[^>]*)?>(.*?)</code>`)
+
+// inlineMarkers maps inline formatting elements to their Markdown delimiters.
+// Underline has no Markdown equivalent, so <u> and <ins> are kept as <u>,
+// which most renderers display.
+var inlineMarkers = map[string][2]string{
+	"b":      {"**", "**"},
+	"strong": {"**", "**"},
+	"em":     {"_", "_"},
+	"i":      {"_", "_"},
+	"del":    {"~~", "~~"},
+	"s":      {"~~", "~~"},
+	"strike": {"~~", "~~"},
+	"u":      {"<u>", "</u>"},
+	"ins":    {"<u>", "</u>"},
+}
+
+// codeMacroPatterns match JIRA's {code} and {noformat} wiki macros.
+var codeMacroPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?s)\{code(?::([^}]*))?\}(.*?)\{code\}`),
+	regexp.MustCompile(`(?s)\{noformat(?::([^}]*))?\}(.*?)\{noformat\}`),
+}
+
+// genericCodeClasses are classes JIRA puts on <pre> that don't name a
+// language.
+var genericCodeClasses = map[string]bool{
+	"code":          true,
+	"noformat":      true,
+	"panel":         true,
+	"panelcontent":  true,
+	"prettyprint":   true,
+	"nowrap":        true,
+	"code-none":     true,
+	"code-noformat": true,
+}
+
+// extractPreBlocks replaces every <pre>...</pre> block with a placeholder and
+// returns the rendered fenced code blocks, so their contents are kept out of
+// the other decodeHTML transforms. With lineNumbers, blocks of more than one
+// line are numbered.
+func extractPreBlocks(s string, lineNumbers bool) (string, []string) {
+	var blocks []string
+	var sb strings.Builder
+
+	for {
+		start := indexTag(s, "pre")
+		if start == -1 {
+			break
+		}
+		openEnd := strings.Index(s[start:], ">")
+		if openEnd == -1 {
+			break
+		}
+		openEnd += start + 1
+
+		closeStart := strings.Index(s[openEnd:], "</pre>")
+		if closeStart == -1 {
+			break
+		}
+		closeStart += openEnd
+
+		lang := languageFromClass(attrValue(s[start:openEnd], "class"))
+		inner := s[openEnd:closeStart]
+		if strings.HasPrefix(inner, "<code") {
+			if codeEnd := strings.Index(inner, ">"); codeEnd != -1 {
+				if codeLang := languageFromClass(attrValue(inner[:codeEnd], "class")); codeLang != "" {
+					lang = codeLang
+				}
+				inner = strings.TrimSuffix(inner[codeEnd+1:], "</code>")
+			}
+		}
+
+		sb.WriteString(s[:start])
+		fmt.Fprintf(&sb, "\n\n\x00PRE%d\x00\n\n", len(blocks))
+		code := html.UnescapeString(inner)
+		if lineNumbers {
+			code = numberLines(code)
+		}
+		blocks = append(blocks, renderFence(code, lang))
+		s = s[closeStart+len("</pre>"):]
+	}
+	sb.WriteString(s)
+
+	return sb.String(), blocks
+}
+
+// extractCodeMacros sets aside {code} and {noformat} macros the same way as
+// extractPreBlocks, appending them to blocks. The language comes from
+// {code:java} or a language= parameter. Macro contents are HTML-decoded only
+// when unescape is set, since wiki markup isn't HTML-escaped.
+func extractCodeMacros(s string, blocks []string, lineNumbers, unescape bool) (string, []string) {
+	for _, pattern := range codeMacroPatterns {
+		s = pattern.ReplaceAllStringFunc(s, func(macro string) string {
+			m := pattern.FindStringSubmatch(macro)
+			code := m[2]
+			if unescape {
+				code = html.UnescapeString(code)
+			}
+			if lineNumbers {
+				code = numberLines(code)
+			}
+			placeholder := fmt.Sprintf("\n\n\x00PRE%d\x00\n\n", len(blocks))
+			blocks = append(blocks, renderFence(code, macroLanguage(m[1])))
+			return placeholder
+		})
+	}
+	return s, blocks
+}
+
+// extractInlineCode sets aside inline <code> spans the same way as
+// extractPreBlocks, appending them to blocks, so their contents aren't
+// formatted. The placeholder stays within the line.
+func extractInlineCode(s string, blocks []string) (string, []string) {
+	s = inlineCodePattern.ReplaceAllStringFunc(s, func(span string) string {
+		code := html.UnescapeString(inlineCodePattern.FindStringSubmatch(span)[1])
+		placeholder := fmt.Sprintf("\x00PRE%d\x00", len(blocks))
+		blocks = append(blocks, renderCodeSpan(code))
+		return placeholder
+	})
+	return s, blocks
+}
+
+// renderCodeSpan wraps code in backticks, using more of them than the
+// longest backtick run inside it.
+func renderCodeSpan(code string) string {
+	longest, run := 0, 0
+	for _, r := range code {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+
+	fence := strings.Repeat("`", longest+1)
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		code = " " + code + " "
+	}
+
+	return fence + code + fence
+}
+
+// convertHTMLHeadings converts <h1> through <h6> to Markdown headings on
+// their own lines. Body headings are demoted two levels, so an <h1> becomes
+// "###" and nests under the document's "##" sections, and then shifted by
+// --heading-offset. The empty anchors JIRA puts in headings are dropped.
+func convertHTMLHeadings(s string, config Config) string {
+	return htmlHeadingPattern.ReplaceAllStringFunc(s, func(heading string) string {
+		m := htmlHeadingPattern.FindStringSubmatch(heading)
+		text := anchorPattern.ReplaceAllString(m[2], "")
+		text = strings.Join(strings.Fields(text), " ")
+		if text == "" {
+			return "\n\n"
+		}
+		level := headingLevel(int(m[1][0]-'0')+2, config)
+		return "\n\n" + strings.Repeat("#", level) + " " + text + "\n\n"
+	})
+}
+
+// convertHTMLInline converts bold, italic and strikethrough elements to
+// their Markdown delimiters, per inlineMarkers. Nested elements nest their
+// delimiters, so <b><em>x</em></b> becomes **_x_**.
+func convertHTMLInline(s string) string {
+	return inlineTagPattern.ReplaceAllStringFunc(s, func(tag string) string {
+		m := inlineTagPattern.FindStringSubmatch(tag)
+		markers := inlineMarkers[m[2]]
+		if m[1] == "/" {
+			return markers[1]
+		}
+		return markers[0]
+	})
+}
+
+// macroLanguage returns the language named in {code} macro parameters such
+// as "java" or "title=Foo.java|language=java".
+func macroLanguage(params string) string {
+	for _, param := range strings.Split(params, "|") {
+		param = strings.TrimSpace(param)
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return param
+		}
+		if key == "language" || key == "lang" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// restorePreBlocks swaps the placeholders left by extractPreBlocks back for
+// their fenced code blocks.
+func restorePreBlocks(s string, blocks []string) string {
+	for i, block := range blocks {
+		s = strings.Replace(s, fmt.Sprintf("\x00PRE%d\x00", i), block, 1)
+	}
+	return s
+}
+
+// extractKeptTags replaces every opening and closing tag named in keep with a
+// placeholder, so --keep-tags tags survive decodeHTML as raw HTML. Their
+// attributes are kept as written.
+func extractKeptTags(s string, keep []string) (string, []string) {
+	if len(keep) == 0 {
+		return s, nil
+	}
+
+	var tags []string
+	var sb strings.Builder
+	for {
+		start := strings.IndexByte(s, '<')
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(s[start:], '>')
+		if end == -1 {
+			break
+		}
+		end += start + 1
+
+		sb.WriteString(s[:start])
+		if tag := s[start:end]; keptTag(tagName(tag), keep) {
+			fmt.Fprintf(&sb, "\x00TAG%d\x00", len(tags))
+			tags = append(tags, tag)
+		} else {
+			sb.WriteString(tag)
+		}
+		s = s[end:]
+	}
+	sb.WriteString(s)
+
+	return sb.String(), tags
+}
+
+// restoreKeptTags swaps the placeholders left by extractKeptTags back for the
+// original tags.
+func restoreKeptTags(s string, tags []string) string {
+	for i, tag := range tags {
+		s = strings.Replace(s, fmt.Sprintf("\x00TAG%d\x00", i), tag, 1)
+	}
+	return s
+}
+
+// tagName returns the lowercased element name of an opening or closing tag
+// such as "<kbd>" or "</SUP>", or "" for anything else.
+func tagName(tag string) string {
+	name := strings.TrimPrefix(strings.TrimPrefix(tag, "<"), "/")
+	end := 0
+	for end < len(name) && (name[end] >= 'a' && name[end] <= 'z' || name[end] >= 'A' && name[end] <= 'Z' || end > 0 && name[end] >= '0' && name[end] <= '9') {
+		end++
+	}
+	return strings.ToLower(name[:end])
+}
+
+// keptTag reports whether name is listed in keep.
+func keptTag(name string, keep []string) bool {
+	if name == "" {
+		return false
+	}
+	for _, k := range keep {
+		if strings.EqualFold(strings.TrimSpace(k), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderFence wraps code in a fenced block, lengthening the fence if the code
+// itself contains backtick runs.
+func renderFence(code, lang string) string {
+	code = strings.Trim(code, "\n")
+
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+// numberLines prefixes each line of code with its right-aligned line number.
+// Single-line code is returned unchanged.
+func numberLines(code string) string {
+	lines := strings.Split(strings.Trim(code, "\n"), "\n")
+	if len(lines) < 2 {
+		return code
+	}
+
+	width := len(fmt.Sprint(len(lines)))
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(fmt.Sprintf("%*d  %s", width, i+1, line), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// languageFromClass extracts the language from a class attribute such as
+// "language-java", "code-java" (as JIRA renders it) or just "java".
+func languageFromClass(class string) string {
+	classes := strings.Fields(class)
+	for _, c := range classes {
+		if genericCodeClasses[strings.ToLower(c)] {
+			continue
+		}
+		for _, prefix := range []string{"language-", "lang-", "code-"} {
+			if lang, ok := strings.CutPrefix(c, prefix); ok && lang != "" {
+				return lang
+			}
+		}
+	}
+	// A lone class such as class="java" names the language itself
+	if len(classes) == 1 && !genericCodeClasses[strings.ToLower(classes[0])] {
+		return classes[0]
+	}
+	return ""
+}
+
+// convertHTMLLists converts <ul> and <ol> lists to Markdown. Items of an
+// unordered list become "- " bullets and items of an ordered list are
+// numbered from 1, or from the list's start attribute. Nested lists are
+// indented to the content of their parent item (two spaces under "- ",
+// three under "1. "), which CommonMark needs to nest them.
+func convertHTMLLists(s string) string {
+	type list struct {
+		ordered bool
+		next    int
+		indent  int // column of the list's markers
+		content int // column of the text of its last item
+	}
+
+	var sb strings.Builder
+	var stack []list
+	atLineStart := func() bool {
+		out := sb.String()
+		return out == "" || strings.HasSuffix(out, "\n")
+	}
+
+	last := 0
+	for _, m := range listTagPattern.FindAllStringSubmatchIndex(s, -1) {
+		sb.WriteString(s[last:m[0]])
+		last = m[1]
+
+		closing := m[3] > m[2]
+		name := s[m[4]:m[5]]
+		switch {
+		case name == "li" && closing:
+			if !atLineStart() {
+				sb.WriteString("\n")
+			}
+		case name == "li":
+			if !atLineStart() {
+				sb.WriteString("\n")
+			}
+			if len(stack) == 0 {
+				// A stray item outside any list
+				sb.WriteString("- ")
+				continue
+			}
+			top := &stack[len(stack)-1]
+			marker := "- "
+			if top.ordered {
+				marker = fmt.Sprintf("%d. ", top.next)
+				top.next++
+			}
+			sb.WriteString(strings.Repeat(" ", top.indent) + marker)
+			top.content = top.indent + len(marker)
+		case closing:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			// Keep following text from running into the last item
+			if len(stack) == 0 {
+				if !atLineStart() {
+					sb.WriteString("\n")
+				}
+				sb.WriteString("\n")
+			}
+		default:
+			// A nested list starts on the line after its parent item's text
+			if len(stack) > 0 && !atLineStart() {
+				sb.WriteString("\n")
+			}
+			l := list{ordered: name == "ol", next: 1}
+			if len(stack) > 0 {
+				l.indent = stack[len(stack)-1].content
+			}
+			if m[6] != -1 {
+				if start, err := strconv.Atoi(attrValue(s[m[6]:m[7]], "start")); err == nil {
+					l.next = start
+				}
+			}
+			stack = append(stack, l)
+		}
+	}
+	sb.WriteString(s[last:])
+
+	return sb.String()
+}
+
+// indexTag returns the index of the first opening tag with the given name,
+// matching "<name>" and "<name " but not longer names sharing the prefix.
+func indexTag(s, name string) int {
+	offset := 0
+	for {
+		i := strings.Index(s[offset:], "<"+name)
+		if i == -1 {
+			return -1
+		}
+		i += offset
+		end := i + 1 + len(name)
+		if end < len(s) && (s[end] == '>' || s[end] == ' ' || s[end] == '/' || s[end] == '\t' || s[end] == '\n') {
+			return i
+		}
+		offset = end
+	}
+}
+
+// attrValue returns the double-quoted value of attribute name within tag.
+func attrValue(tag, name string) string {
+	needle := " " + name + "=\""
+	start := strings.Index(tag, needle)
+	if start == -1 {
+		return ""
+	}
+	start += len(needle)
+	end := strings.Index(tag[start:], "\"")
+	if end == -1 {
+		return ""
+	}
+	return tag[start : start+end]
+}
+
+// absoluteURLs resolves server-relative link and image targets such as
+// "/secure/attachment/1/shot.png" against the JIRA host in base, so images
+// and attachments embedded in bodies still resolve outside JIRA. Fenced code
+// is left alone.
+func absoluteURLs(md, base string) string {
+	u, err := url.Parse(base)
+	if err != nil || u.Scheme == "" || u.Host == "" || !strings.Contains(md, "](/") {
+		return md
+	}
+	host := u.Scheme + "://" + u.Host
+
+	return mapOutsideFences(md, func(line string) string {
+		// "](//" is protocol-relative and already names a host
+		line = strings.ReplaceAll(line, "](//", "]\x00")
+		line = strings.ReplaceAll(line, "](/", "]("+host+"/")
+		return strings.ReplaceAll(line, "]\x00", "](//")
+	})
+}
+
+// mapOutsideFences applies fn to every line of md that is not part of a
+// fenced code block. Fence lines themselves are left alone.
+func mapOutsideFences(md string, fn func(string) string) string {
+	lines := strings.Split(md, "\n")
+	fence := ""
+	for i, line := range lines {
+		if marker := fenceMarker(strings.TrimLeft(line, " ")); marker != "" {
+			if fence == "" {
+				fence = marker
+			} else if marker[0] == fence[0] && len(marker) >= len(fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence == "" {
+			lines[i] = fn(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// trimTrailingWhitespace strips trailing spaces and tabs from every line of
+// md outside fenced code. A run of exactly two spaces after text is kept, as
+// it marks a hard line break.
+func trimTrailingWhitespace(md string) string {
+	return mapOutsideFences(md, func(line string) string {
+		trimmed := strings.TrimRight(line, " \t")
+		if trimmed != "" && line == trimmed+"  " {
+			return line
+		}
+		return trimmed
+	})
+}
+
+// normalizeNewlines converts CRLF and lone CR line endings, the latter from
+// old Mac exports, to LF.
+func normalizeNewlines(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\r", "\n")
+}
+
+// tableCell makes s safe to place in a Markdown table cell by escaping pipes
+// and turning line breaks into <br>.
+func tableCell(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.ReplaceAll(s, "|", "\\|")
+	s = normalizeNewlines(s)
+	s = strings.ReplaceAll(s, "\n\n", "<br>")
+	s = strings.ReplaceAll(s, "\n", "<br>")
+	return s
+}
@@ -0,0 +1,140 @@
+package main
+
+import "testing"
+
+func TestDecodeHTMLPreBlocks(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "escaped angle brackets",
+			in:   "<pre>if (a &lt; b &amp;&amp; c &gt; d) {}</pre>",
+			want: "```\nif (a < b && c > d) {}\n```",
+		},
+		{
+			name: "generics and tags stay code",
+			in:   "<pre class=\"code-java\">List&lt;String&gt; xs = new ArrayList&lt;&gt;();\n// &lt;b&gt;not bold&lt;/b&gt;</pre>",
+			want: "```java\nList<String> xs = new ArrayList<>();\n// <b>not bold</b>\n```",
+		},
+		{
+			name: "inside code element",
+			in:   "<p>Run:</p><pre><code class=\"language-html\">&lt;div class=&quot;x&quot;&gt;&lt;/div&gt;</code></pre>",
+			want: "Run:\n\n```html\n<div class=\"x\"></div>\n```",
+		},
+		{
+			name: "fence in code",
+			in:   "<pre>```\n&lt;tag&gt;\n```</pre>",
+			want: "````\n```\n<tag>\n```\n````",
+		},
+	}
+
+	config := testConfig(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeHTML(tt.in, config); got != tt.want {
+				t.Errorf("decodeHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertHTMLHeadings(t *testing.T) {
+	tests := []struct {
+		in     string
+		offset int
+		want   string
+	}{
+		{"<h1>Title</h1>", 0, "\n\n### Title\n\n"},
+		{"<h2>Title</h2>", 0, "\n\n#### Title\n\n"},
+		{"<h3>Title</h3>", 0, "\n\n##### Title\n\n"},
+		{"<h4>Title</h4>", 0, "\n\n###### Title\n\n"},
+		{"<h5>Title</h5>", 0, "\n\n###### Title\n\n"},
+		{"<h6>Title</h6>", 0, "\n\n###### Title\n\n"},
+		{"<h1>Title</h1>", -2, "\n\n# Title\n\n"},
+		{"<h1>Title</h1>", 1, "\n\n#### Title\n\n"},
+		{`<h2 id="x"><a name="Setup"></a>Set
+  up</h2>`, 0, "\n\n#### Set up\n\n"},
+		{`<h3><a name="empty"></a></h3>`, 0, "\n\n"},
+	}
+
+	for _, tt := range tests {
+		config := testConfig(t)
+		config.headingOffset = tt.offset
+		if got := convertHTMLHeadings(tt.in, config); got != tt.want {
+			t.Errorf("convertHTMLHeadings(%q) with offset %d = %q, want %q", tt.in, tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestCarriageReturnLineEndings(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		in     string // description as it appears in the export's XML
+		want   string
+	}{
+		{"literal CRs", "html", "first\rsecond\r\rthird", "first\nsecond\n\nthird"},
+		{"CR references", "html", "first&#13;second&#13;&#13;third", "first\nsecond\n\nthird"},
+		{"CRLF references", "html", "first&#13;&#10;second", "first\nsecond"},
+		{"pre block", "html", "&lt;pre&gt;a&#13;b&#13;&lt;/pre&gt;", "```\na\nb\n```"},
+		{"wiki list", "wiki", "* one&#13;* two&#13;after", "- one\n- two\n\nafter"},
+		{"wiki heading", "wiki", "h1. Title&#13;text", "### Title\ntext"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig(t, "--input-format", tt.format)
+			description := testItems(t, config, "<item><key>AI-1</key><description>"+tt.in+"</description></item>")[0].Description
+			if got := decodeHTML(description, config); got != tt.want {
+				t.Errorf("decodeHTML(%q) = %q, want %q", description, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableCellCarriageReturns(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"a\rb", "a<br>b"},
+		{"a\r\rb", "a<br>b"},
+		{"a\r\nb", "a<br>b"},
+		{"a|b\r", "a\\|b"},
+	}
+
+	for _, tt := range tests {
+		if got := tableCell(tt.in); got != tt.want {
+			t.Errorf("tableCell(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeHTMLEntities(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"nbsp", "a&nbsp;b", "a\u00a0b"},
+		{"numeric ellipsis", "Wait&#8230;", "Wait…"},
+		{"hex ellipsis", "Wait&#x2026;", "Wait…"},
+		{"named", "&copy; 2024 &mdash; &euro;5", "© 2024 — €5"},
+		{"double escaped ampersand", "Tom &amp;amp; Jerry", "Tom &amp; Jerry"},
+		{"double escaped tag", "&amp;lt;b&amp;gt;", "&lt;b&gt;"},
+		{"escaped tag stays text", "&lt;b&gt;bold?&lt;/b&gt;", "<b>bold?</b>"},
+		{"entities in formatting", "<b>a&nbsp;&amp;&nbsp;b</b>", "**a\u00a0&\u00a0b**"},
+		{"entity in link text", `<a href="https://example.com/?a=1&amp;b=2">Q&amp;A</a>`, "[Q&A](https://example.com/?a=1&b=2)"},
+	}
+
+	config := testConfig(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeHTML(tt.in, config); got != tt.want {
+				t.Errorf("decodeHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
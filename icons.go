@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// defaultStatusIcons are the --icons prefixes for common status names.
+var defaultStatusIcons = map[string]string{
+	"open":        "⚪",
+	"to do":       "⚪",
+	"backlog":     "⚪",
+	"new":         "⚪",
+	"reopened":    "⚪",
+	"in progress": "🔵",
+	"in review":   "🔵",
+	"blocked":     "🔴",
+	"on hold":     "🟡",
+	"resolved":    "🟢",
+	"done":        "🟢",
+	"closed":      "🟢",
+	"won't do":    "⚫",
+	"cancelled":   "⚫",
+}
+
+// defaultPriorityIcons are the --icons prefixes for JIRA's built-in priority
+// names, old and new.
+var defaultPriorityIcons = map[string]string{
+	"blocker":  "🔴",
+	"highest":  "🔴",
+	"critical": "🟠",
+	"high":     "🟠",
+	"major":    "🟡",
+	"medium":   "🟡",
+	"minor":    "🔵",
+	"low":      "🔵",
+	"trivial":  "⚪",
+	"lowest":   "⚪",
+}
+
+// iconsFile is the format of --icons-file: status and priority names mapped
+// to the emoji to show before them.
+type iconsFile struct {
+	Status   map[string]string `json:"status"`
+	Priority map[string]string `json:"priority"`
+}
+
+// loadIcons returns the built-in status and priority icons with the
+// overrides from path applied. An empty icon removes a built-in one.
+func loadIcons(path string) (map[string]string, map[string]string, error) {
+	status := copyIcons(defaultStatusIcons, nil)
+	priority := copyIcons(defaultPriorityIcons, nil)
+	if path == "" {
+		return status, priority, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read icons file: %w", err)
+	}
+	var overrides iconsFile
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse icons file: %w", err)
+	}
+
+	return copyIcons(status, overrides.Status), copyIcons(priority, overrides.Priority), nil
+}
+
+// copyIcons returns icons with overrides applied, keyed by lowercased name.
+func copyIcons(icons, overrides map[string]string) map[string]string {
+	out := make(map[string]string, len(icons)+len(overrides))
+	for name, icon := range icons {
+		out[name] = icon
+	}
+	for name, icon := range overrides {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if icon == "" {
+			delete(out, name)
+		} else {
+			out[name] = icon
+		}
+	}
+	return out
+}
+
+// withIcon prefixes value with its icon from icons, if it has one.
+func withIcon(value string, icons map[string]string) string {
+	if icon, ok := icons[strings.ToLower(strings.TrimSpace(value))]; ok {
+		return icon + " " + value
+	}
+	return value
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// imageClient fetches remote images for --inline-images and
+// --download-images.
+var imageClient = &http.Client{Timeout: 30 * time.Second}
+
+// maxDownloadImage is the largest image saved by --download-images.
+const maxDownloadImage = 64 << 20
+
+var imgSrcPattern = regexp.MustCompile(`<img src="([^"]*)"`)
+
+// imageExtensions maps image content types to file extensions for
+// --download-images.
+var imageExtensions = map[string]string{
+	"image/png":     ".png",
+	"image/jpeg":    ".jpg",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/svg+xml": ".svg",
+	"image/bmp":     ".bmp",
+}
+
+// fetchImage downloads the image at url and returns its bytes and content
+// type. Responses larger than limit bytes are rejected without reading them
+// in full.
+func fetchImage(url string, limit int64) ([]byte, string, error) {
+	resp, err := imageClient.Get(url)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if resp.ContentLength > limit {
+		return nil, "", fmt.Errorf("image is %d bytes, over the %d byte limit", resp.ContentLength, limit)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > limit {
+		return nil, "", fmt.Errorf("image is over the %d byte limit", limit)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if i := strings.IndexByte(contentType, ';'); i != -1 {
+		contentType = contentType[:i]
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, "", fmt.Errorf("not an image (%s)", contentType)
+	}
+
+	return data, contentType, nil
+}
+
+// inlineImages replaces remote Markdown images in md with base64 data URIs.
+// Images over --inline-image-max, or that can't be fetched, are left as
+// links. Each URL is fetched once per document.
+func inlineImages(md string, config Config) string {
+	cache := make(map[string]string)
+	inline := func(url string) string {
+		if uri, ok := cache[url]; ok {
+			return uri
+		}
+		uri := url
+		data, contentType, err := fetchImage(url, config.inlineImageBytes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: not inlining image %s: %v\n", url, err)
+		} else {
+			uri = "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+		}
+		cache[url] = uri
+		return uri
+	}
+
+	return mapOutsideFences(md, func(line string) string {
+		var sb strings.Builder
+		for {
+			start := strings.Index(line, "![")
+			if start == -1 {
+				break
+			}
+			altEnd := closingBracket(line, start+1)
+			if altEnd == -1 || altEnd+1 >= len(line) || line[altEnd+1] != '(' {
+				sb.WriteString(line[:start+2])
+				line = line[start+2:]
+				continue
+			}
+			urlEnd := strings.IndexByte(line[altEnd+2:], ')')
+			if urlEnd == -1 {
+				break
+			}
+			urlEnd += altEnd + 2
+
+			// The destination may be followed by a "title"
+			url, title, _ := strings.Cut(line[altEnd+2:urlEnd], " ")
+			sb.WriteString(line[:altEnd+2])
+			if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+				url = inline(url)
+			}
+			sb.WriteString(url)
+			if title != "" {
+				sb.WriteString(" " + title)
+			}
+			line = line[urlEnd:]
+		}
+		sb.WriteString(line)
+		return sb.String()
+	})
+}
+
+// itemImageURLs returns the src of every <img> in the rich-text fields of
+// item, as written in the HTML, in order of first appearance.
+func itemImageURLs(item Item) []string {
+	fields := []string{item.Description, item.RenderedBody, item.Environment}
+	for _, comment := range item.Comments.Comment {
+		fields = append(fields, comment.Value)
+	}
+	for _, wl := range item.Worklogs.Worklog {
+		fields = append(fields, wl.Comment)
+	}
+	for _, cf := range item.CustomFields.CustomField {
+		for _, v := range cf.CustomFieldValues.CustomFieldValue {
+			fields = append(fields, v.Value)
+		}
+	}
+
+	var srcs []string
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		for _, m := range imgSrcPattern.FindAllStringSubmatch(field, -1) {
+			if src := m[1]; src != "" && !seen[src] {
+				seen[src] = true
+				srcs = append(srcs, src)
+			}
+		}
+	}
+	return srcs
+}
+
+// downloadImages saves the images of item into --download-images as KEY-1.png,
+// KEY-2.jpg and so on, and returns their paths relative to outputFile keyed
+// by the src they were embedded with. Server-relative URLs are resolved
+// against the channel link. Images that can't be fetched keep their URL.
+func downloadImages(item Item, outputFile string, config Config, state *runState) (map[string]string, error) {
+	srcs := itemImageURLs(item)
+	if len(srcs) == 0 {
+		return nil, nil
+	}
+
+	base, _ := url.Parse(config.channelLink)
+	paths := make(map[string]string)
+	n := 0
+	for _, src := range srcs {
+		target, err := url.Parse(html.UnescapeString(src))
+		if err != nil {
+			continue
+		}
+		if base != nil {
+			target = base.ResolveReference(target)
+		}
+		if target.Scheme != "http" && target.Scheme != "https" {
+			continue
+		}
+
+		data, contentType, err := fetchImage(target.String(), maxDownloadImage)
+		if err != nil {
+			if config.verbose {
+				fmt.Fprintf(os.Stderr, "Warning: not downloading image %s: %v\n", target, err)
+			}
+			continue
+		}
+
+		ext, ok := imageExtensions[contentType]
+		if !ok {
+			ext = strings.ToLower(path.Ext(target.Path))
+		}
+		n++
+		dest := filepath.Join(config.downloadImages, fmt.Sprintf("%s-%d%s", fileNamePart(item.Key.Value), n, ext))
+
+		if _, err := os.Stat(dest); err == nil && !mayOverwrite(dest, config) {
+			return nil, fmt.Errorf("image file %s already exists (use -f to overwrite)", dest)
+		}
+		if err := os.MkdirAll(config.downloadImages, dirMode(config)); err != nil {
+			return nil, fmt.Errorf("failed to create images directory: %w", err)
+		}
+		if err := writeOutput(dest, data, config); err != nil {
+			return nil, fmt.Errorf("failed to write image: %w", err)
+		}
+		state.addOutput(config.inputFile, dest)
+
+		if config.verbose {
+			fmt.Fprintf(os.Stderr, "Downloaded %s\n", dest)
+		}
+		paths[src] = relativeLink(outputFile, dest)
+	}
+
+	return paths, nil
+}
+
+// relativeLink returns a link to path from the document at outputFile, or
+// from the working directory when the document goes to standard output.
+func relativeLink(outputFile, path string) string {
+	dir := "."
+	if outputFile != "-" {
+		dir = filepath.Dir(outputFile)
+	}
+	absDir, err1 := filepath.Abs(dir)
+	absPath, err2 := filepath.Abs(path)
+	if err1 != nil || err2 != nil {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return filepath.ToSlash(absPath)
+	}
+	return filepath.ToSlash(rel)
+}
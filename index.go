@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// indexEntry is one converted item listed by --index. input and pos are the
+// positions of its input file and of the item within it, which give the
+// run order regardless of which --jobs worker finished first.
+type indexEntry struct {
+	item  Item
+	path  string
+	input int
+	pos   int
+}
+
+// sortRunOrder puts entries back into the order of the inputs and items they
+// were converted from.
+func sortRunOrder(entries []indexEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].input != entries[j].input {
+			return entries[i].input < entries[j].input
+		}
+		return entries[i].pos < entries[j].pos
+	})
+}
+
+// statusRanks orders status categories from open to done.
+var statusRanks = map[string]int{
+	"To Do":       1,
+	"In Progress": 2,
+	"Done":        3,
+}
+
+// sortIndex orders entries for --sort-index-by. Votes, priority and updated
+// put the most voted, most urgent and most recently updated items first;
+// keys sort naturally (AI-2 before AI-10) and statuses by category, then
+// name. Ties keep their run order.
+func sortIndex(entries []indexEntry, by string, config Config) {
+	less := func(a, b Item) bool { return keyLess(a.Key.Value, b.Key.Value) }
+
+	switch by {
+	case "status":
+		less = func(a, b Item) bool {
+			ra, rb := statusRank(a), statusRank(b)
+			if ra != rb {
+				return ra < rb
+			}
+			return strings.ToLower(a.Status.Value) < strings.ToLower(b.Status.Value)
+		}
+	case "priority":
+		less = func(a, b Item) bool { return priorityRank(a.Priority, config) < priorityRank(b.Priority, config) }
+	case "votes":
+		less = func(a, b Item) bool { return votes(a) > votes(b) }
+	case "updated":
+		less = func(a, b Item) bool {
+			ta, okA := parseJiraDate(a.Updated)
+			tb, okB := parseJiraDate(b.Updated)
+			if okA != okB {
+				return okA
+			}
+			return ta.After(tb)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return less(entries[i].item, entries[j].item)
+	})
+}
+
+// statusRank returns the position of an item's status category, with
+// unknown categories after Done.
+func statusRank(item Item) int {
+	if rank, ok := statusRanks[statusCategory(item)]; ok {
+		return rank
+	}
+	return len(statusRanks) + 1
+}
+
+// votes returns the vote count of an item, or 0 if the export has none.
+func votes(item Item) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(item.Votes))
+	return n
+}
+
+// keyLess compares issue keys by project, then numerically by issue number.
+func keyLess(a, b string) bool {
+	pa, na, okA := splitKey(a)
+	pb, nb, okB := splitKey(b)
+	if !okA || !okB || pa != pb {
+		return a < b
+	}
+	return na < nb
+}
+
+// splitKey splits an issue key such as "AI-538" into project and number.
+func splitKey(key string) (string, int, bool) {
+	i := strings.LastIndex(key, "-")
+	if i == -1 {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(key[i+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	return key[:i], n, true
+}
+
+// writeIndex writes a Markdown table linking to every converted item, in
+// --sort-index-by order. Links are relative to the index's directory.
+func writeIndex(path string, entries []indexEntry, config Config) error {
+	sortIndex(entries, config.sortIndexBy, config)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", config.label("index"))
+	fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s | %s |\n",
+		config.label("key"), config.label("summary"), config.label("status"),
+		config.label("priority"), config.label("votes"), config.label("updated"))
+	sb.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+
+	base := filepath.Dir(path)
+	for _, entry := range entries {
+		link := entry.path
+		if rel, err := filepath.Rel(base, entry.path); err == nil {
+			link = rel
+		}
+		item := entry.item
+		fmt.Fprintf(&sb, "| [%s](%s) | %s | %s | %s | %s | %s |\n",
+			tableCell(item.Key.Value), filepath.ToSlash(link), tableCell(redacted(itemSummary(item, config), config)), tableCell(item.Status.Value),
+			tableCell(item.Priority.Value), tableCell(item.Votes), tableCell(formatDate(item.Updated, config)))
+	}
+
+	if err := writeOutput(path, encodeOutput(sb.String(), config), config); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// jsonItem is the cleaned form of an item written by --ndjson: plain values
+// with markup converted, rather than the raw XML structure --dump-parsed
+// shows.
+type jsonItem struct {
+	Key          string              `json:"key"`
+	Summary      string              `json:"summary"`
+	Link         string              `json:"link,omitempty"`
+	Type         string              `json:"type,omitempty"`
+	Priority     string              `json:"priority,omitempty"`
+	Status       string              `json:"status,omitempty"`
+	Resolution   string              `json:"resolution,omitempty"`
+	Assignee     string              `json:"assignee,omitempty"`
+	Reporter     string              `json:"reporter,omitempty"`
+	Labels       []string            `json:"labels,omitempty"`
+	Components   []string            `json:"components,omitempty"`
+	Versions     []string            `json:"versions,omitempty"`
+	Created      string              `json:"created,omitempty"`
+	Updated      string              `json:"updated,omitempty"`
+	Resolved     string              `json:"resolved,omitempty"`
+	Due          string              `json:"due,omitempty"`
+	Description  string              `json:"description"`
+	Comments     []jsonComment       `json:"comments,omitempty"`
+	CustomFields map[string][]string `json:"custom_fields,omitempty"`
+}
+
+// itemDocument is the --format json form of an item: the parsed XML structs
+// as they are, except that rich-text fields are converted to Markdown and
+// custom fields are flattened to name/value pairs. Its fields shadow those of
+// the same name in Item.
+type itemDocument struct {
+	Item
+	Comments     []jsonComment     `json:"Comments,omitempty"`
+	CustomFields []jsonCustomField `json:"CustomFields,omitempty"`
+}
+
+// jsonCustomField is one value of a custom field. A field with several
+// values appears once per value.
+type jsonCustomField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type jsonComment struct {
+	ID      string `json:"id,omitempty"`
+	Author  string `json:"author,omitempty"`
+	Created string `json:"created,omitempty"`
+	Body    string `json:"body"`
+}
+
+// newJSONItem converts item to its cleaned JSON form. Descriptions and
+// comments are converted to Markdown as in the generated files.
+func newJSONItem(item Item, channelLink string, config Config) jsonItem {
+	config.channelLink = channelLink
+
+	description, _ := renderDescription(item, config)
+	ji := jsonItem{
+		Key:         item.Key.Value,
+		Summary:     itemSummary(item, config),
+		Link:        item.Link,
+		Type:        item.Type.Value,
+		Priority:    item.Priority.Value,
+		Status:      item.Status.Value,
+		Resolution:  item.Resolution.Value,
+		Assignee:    item.Assignee.Value,
+		Reporter:    item.Reporter.Value,
+		Labels:      item.Labels.Label,
+		Components:  item.Components.Component,
+		Versions:    item.Versions.Version,
+		Created:     item.Created,
+		Updated:     item.Updated,
+		Resolved:    item.Resolved,
+		Due:         item.Due,
+		Description: description,
+	}
+
+	for _, comment := range item.Comments.Comment {
+		if !commentShown(comment, config) {
+			continue
+		}
+		ji.Comments = append(ji.Comments, jsonComment{
+			ID:      comment.ID,
+			Author:  comment.Author,
+			Created: comment.Created,
+			Body:    renderBody(comment.Value, config),
+		})
+	}
+
+	if config.details {
+		for _, cf := range item.CustomFields.CustomField {
+			values := customFieldValues(cf)
+			if len(values) == 0 {
+				continue
+			}
+			if ji.CustomFields == nil {
+				ji.CustomFields = make(map[string][]string)
+			}
+			ji.CustomFields[cf.CustomFieldName] = values
+		}
+	}
+
+	if len(config.redactions) > 0 {
+		ji.redact(config)
+	}
+
+	return ji
+}
+
+// redact applies --redact-pattern to every string field of ji.
+func (ji *jsonItem) redact(config Config) {
+	for _, s := range []*string{
+		&ji.Key, &ji.Summary, &ji.Link, &ji.Type, &ji.Priority, &ji.Status, &ji.Resolution,
+		&ji.Assignee, &ji.Reporter, &ji.Created, &ji.Updated, &ji.Resolved, &ji.Due, &ji.Description,
+	} {
+		*s = redacted(*s, config)
+	}
+	// The lists are shared with the item, so they are copied, not changed
+	for _, list := range []*[]string{&ji.Labels, &ji.Components, &ji.Versions} {
+		var values []string
+		for _, v := range *list {
+			values = append(values, redacted(v, config))
+		}
+		*list = values
+	}
+	for i := range ji.Comments {
+		c := &ji.Comments[i]
+		c.ID, c.Author, c.Created, c.Body = redacted(c.ID, config), redacted(c.Author, config), redacted(c.Created, config), redacted(c.Body, config)
+	}
+
+	fields := make(map[string][]string, len(ji.CustomFields))
+	for name, values := range ji.CustomFields {
+		var clean []string
+		for _, v := range values {
+			clean = append(clean, redacted(v, config))
+		}
+		fields[redacted(name, config)] = clean
+	}
+	if ji.CustomFields != nil {
+		ji.CustomFields = fields
+	}
+}
+
+// newItemDocument converts item to its --format json form.
+func newItemDocument(item Item, config Config) itemDocument {
+	item.Description, _ = renderDescription(item, config)
+	item.Environment = renderBody(item.Environment, config)
+	if item.RenderedBody != "" {
+		html := config
+		html.inputFormat = "html"
+		item.RenderedBody = renderBody(item.RenderedBody, html)
+	}
+
+	// Copied so the caller's item keeps its markup
+	worklogs := make([]Worklog, len(item.Worklogs.Worklog))
+	for i, wl := range item.Worklogs.Worklog {
+		wl.Comment = renderBody(wl.Comment, config)
+		worklogs[i] = wl
+	}
+	item.Worklogs.Worklog = worklogs
+
+	doc := itemDocument{Item: item}
+	for _, comment := range item.Comments.Comment {
+		if !commentShown(comment, config) {
+			continue
+		}
+		doc.Comments = append(doc.Comments, jsonComment{
+			ID:      comment.ID,
+			Author:  comment.Author,
+			Created: comment.Created,
+			Body:    renderBody(comment.Value, config),
+		})
+	}
+
+	if config.details {
+		for _, cf := range item.CustomFields.CustomField {
+			name := strings.TrimSpace(cf.CustomFieldName)
+			for _, value := range customFieldValues(cf) {
+				doc.CustomFields = append(doc.CustomFields, jsonCustomField{name, value})
+			}
+		}
+	}
+
+	if len(config.redactions) > 0 {
+		redactValue(reflect.ValueOf(&doc).Elem(), config)
+	}
+
+	return doc
+}
+
+// redactValue applies --redact-pattern to every string in v, which must be
+// settable. Slices are copied before their elements are changed, since they
+// may be shared with the parsed item.
+func redactValue(v reflect.Value, config Config) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(redacted(v.String(), config))
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				redactValue(v.Field(i), config)
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		values := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(values, v)
+		for i := 0; i < values.Len(); i++ {
+			redactValue(values.Index(i), config)
+		}
+		v.Set(values)
+	}
+}
+
+// itemJSON returns item as indented JSON for --format json. Redactions are
+// applied to the values before encoding, so a match can't take JSON syntax
+// with it.
+func itemJSON(item Item, config Config) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(newItemDocument(item, config)); err != nil {
+		return "", fmt.Errorf("failed to encode %s: %w", item.Key.Value, err)
+	}
+
+	return buf.String(), nil
+}
+
+// jsonArray joins the JSON documents of several items into one indented
+// array.
+func jsonArray(docs []string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte("["+strings.Join(docs, ",")+"]"), "", "  "); err != nil {
+		// Each document was produced by itemJSON, so this can't happen
+		return "[" + strings.Join(docs, ",") + "]\n"
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+// streamNDJSON writes every selected item of inputFile to w as one line of
+// JSON, decoding the export an item at a time so huge exports don't have to
+// fit in memory.
+func streamNDJSON(inputFile string, w io.Writer, config Config) error {
+	var r io.Reader = os.Stdin
+	var tooLarge error
+	if inputFile != "-" {
+		if err := checkInputSize(inputFile, config); err != nil {
+			return err
+		}
+		f, err := os.Open(inputFile)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	} else if config.maxFileBytes > 0 {
+		tooLarge = fmt.Errorf("standard input is larger than --max-file-size %s", config.maxFileSize)
+		r = &maxSizeReader{os.Stdin, config.maxFileBytes, tooLarge}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	count := 0
+	err := streamItems(r, config, func(item Item, channelLink string) error {
+		count++
+		if !keySelected(item.Key.Value, config.onlyKeys) || !priorityAtLeast(item.Priority, config) {
+			return nil
+		}
+		if config.dedupeComments {
+			item.Comments.Comment, _ = dedupeComments(item.Comments.Comment)
+		}
+		item.Link = publicURL(item.Link, config)
+		return enc.Encode(newJSONItem(item, channelLink, config))
+	})
+	if tooLarge != nil && errors.Is(err, tooLarge) {
+		return tooLarge
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse XML: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("no items found in XML")
+	}
+
+	return nil
+}
+
+// maxSizeReader reads from r, failing with err once more than n bytes have
+// been read, so input over --max-file-size is rejected rather than cut short.
+type maxSizeReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	m.n -= int64(n)
+	if m.n < 0 {
+		return 0, m.err
+	}
+	return n, err
+}
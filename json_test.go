@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestItemJSONRedaction(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		want     string
+	}{
+		{"match runs to the end of the value", []string{`token=\S+`}, "Use [REDACTED]\n\n```\npassword: hunter2\nnext\n```"},
+		{"match inside a code block", []string{`password: \S+`}, "Use token=abc123\n\n```\n[REDACTED]\nnext\n```"},
+		{"replacement", []string{`token=\S+=>token=***`}, "Use token=***\n\n```\npassword: hunter2\nnext\n```"},
+		{"no match", []string{`secret`}, "Use token=abc123\n\n```\npassword: hunter2\nnext\n```"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args []string
+			for _, p := range tt.patterns {
+				args = append(args, "--redact-pattern", p)
+			}
+			config := testConfig(t, args...)
+
+			item := Item{Key: Key{Value: "AI-1"}, Description: "<p>Use token=abc123</p><pre>password: hunter2\nnext</pre>"}
+			item.Labels.Label = []string{"token=abc123"}
+			out, err := itemJSON(item, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var doc struct {
+				Description string
+				Labels      struct{ Label []string }
+			}
+			if err := json.Unmarshal([]byte(out), &doc); err != nil {
+				t.Fatalf("itemJSON wrote invalid JSON: %v\n%s", err, out)
+			}
+			if doc.Description != tt.want {
+				t.Errorf("Description = %q, want %q", doc.Description, tt.want)
+			}
+			if item.Labels.Label[0] != "token=abc123" {
+				t.Errorf("itemJSON changed the caller's labels to %q", item.Labels.Label)
+			}
+		})
+	}
+}
+
+func TestMaxSizeReader(t *testing.T) {
+	tooLarge := errors.New("too large")
+	tests := []struct {
+		size    int
+		limit   int64
+		wantErr bool
+	}{
+		{0, 10, false},
+		{9, 10, false},
+		{10, 10, false},
+		{11, 10, true},
+		{100000, 10, true},
+	}
+
+	for _, tt := range tests {
+		data, err := io.ReadAll(&maxSizeReader{strings.NewReader(strings.Repeat("x", tt.size)), tt.limit, tooLarge})
+		if tt.wantErr {
+			if !errors.Is(err, tooLarge) {
+				t.Errorf("reading %d bytes with limit %d: err = %v, want %v", tt.size, tt.limit, err, tooLarge)
+			}
+			continue
+		}
+		if err != nil || len(data) != tt.size {
+			t.Errorf("reading %d bytes with limit %d: got %d bytes, err %v", tt.size, tt.limit, len(data), err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// defaultLabels holds the English section headings and field labels written
+// by generateMarkdown. Any of them can be overridden with --labels-file.
+var defaultLabels = map[string]string{
+	// Section headings
+	"contents":          "Contents",
+	"overview":          "Overview",
+	"dates":             "Dates",
+	"details":           "Details",
+	"comments":          "Comments",
+	"custom_fields":     "Custom Fields",
+	"audit_description": "Audit Description",
+	"attachments":       "Attachments",
+	"index":             "Index",
+	"related_links":     "Related Links",
+	"dependencies":      "Dependencies",
+	"related_issues":    "Related Issues",
+	"timeline":          "Timeline",
+	"recent_activity":   "Recent Activity",
+	"environment":       "Environment",
+	"time_tracking":     "Time Tracking",
+	"work_log":          "Work Log",
+	"what_changed":      "What Changed",
+	"description":       "Description",
+	"new_comments":      "New Comments",
+
+	// Field labels
+	"link":       "Link",
+	"key":        "Key",
+	"summary":    "Summary",
+	"votes":      "Votes",
+	"type":       "Type",
+	"priority":   "Priority",
+	"status":     "Status",
+	"resolution": "Resolution",
+	"assignee":   "Assignee",
+	"reporter":   "Reporter",
+	"labels":     "Labels",
+	"components": "Components",
+	"versions":   "Versions",
+	"created":    "Created",
+	"updated":    "Updated",
+	"resolved":   "Resolved",
+	"due":        "Due",
+	"size":       "Size",
+	"bytes":      "bytes",
+	"author":     "Author",
+	"started":    "Started",
+	"time_spent": "Time Spent",
+	"comment":    "Comment",
+	"parent":     "Parent",
+	"subtasks":   "Subtasks",
+
+	// Time tracking
+	"original_estimate":  "Original Estimate",
+	"remaining_estimate": "Remaining Estimate",
+
+	// Activity digest
+	"commented":   "commented",
+	"logged_work": "logged",
+
+	// Panels
+	"info":    "Info",
+	"tip":     "Tip",
+	"note":    "Note",
+	"warning": "Warning",
+	"error":   "Error",
+
+	// Notes
+	"permalink":             "Permalink",
+	"comment_file":          "Comment file",
+	"no_description":        "No description.",
+	"filtered_out":          "filtered out",
+	"unparsed_date":         "unrecognized date",
+	"older_comments_hidden": "older comment(s) hidden",
+	"unresolved":            "Unresolved",
+	"no_changes":            "No changes since baseline.",
+	"original_html":         "Original HTML",
+}
+
+// loadLabels reads a JSON object mapping label keys to replacement strings
+// and returns the defaults with those overrides applied.
+func loadLabels(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read labels file: %w", err)
+	}
+
+	var overrides map[string]string
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse labels file: %w", err)
+	}
+
+	labels := make(map[string]string, len(defaultLabels))
+	for key, value := range defaultLabels {
+		labels[key] = value
+	}
+	for key, value := range overrides {
+		if _, ok := defaultLabels[key]; !ok {
+			return nil, fmt.Errorf("unknown label %q in labels file", key)
+		}
+		labels[key] = value
+	}
+
+	return labels, nil
+}
+
+// label returns the heading or field label for key, falling back to the
+// English default.
+func (c Config) label(key string) string {
+	if value, ok := c.labels[key]; ok {
+		return value
+	}
+	return defaultLabels[key]
+}
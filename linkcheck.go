@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// linkCheckConcurrency is how many links --check-links checks at once.
+const linkCheckConcurrency = 8
+
+// linkClient checks links for --check-links.
+var linkClient = &http.Client{Timeout: 10 * time.Second}
+
+var httpURLPattern = regexp.MustCompile(`https?://[^\s()<>\[\]"'` + "`" + `]+`)
+
+// checkLinks requests every distinct HTTP(S) URL in md and returns a
+// description of each one that doesn't resolve, in order of appearance.
+func checkLinks(md string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, url := range httpURLPattern.FindAllString(md, -1) {
+		// Sentence punctuation directly after a bare URL isn't part of it
+		url = strings.TrimRight(url, ".,;:!?")
+		if !seen[url] {
+			seen[url] = true
+			urls = append(urls, url)
+		}
+	}
+
+	problems := make([]string, len(urls))
+	sem := make(chan struct{}, linkCheckConcurrency)
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, url string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := checkLink(url); err != nil {
+				problems[i] = fmt.Sprintf("%s (%v)", url, err)
+			}
+		}(i, url)
+	}
+	wg.Wait()
+
+	var broken []string
+	for _, problem := range problems {
+		if problem != "" {
+			broken = append(broken, problem)
+		}
+	}
+	return broken
+}
+
+// checkLink sends a HEAD request for url, falling back to GET for servers
+// that don't support HEAD, and returns an error unless the response is a
+// success or redirect.
+func checkLink(url string) error {
+	resp, err := linkClient.Head(url)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = linkClient.Get(url)
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+type IssueLinks struct {
+	IssueLinkType []IssueLinkType `xml:"issuelinktype"`
+}
+
+type IssueLinkType struct {
+	ID           string    `xml:"id,attr"`
+	Name         string    `xml:"name"`
+	OutwardLinks LinkGroup `xml:"outwardlinks"`
+	InwardLinks  LinkGroup `xml:"inwardlinks"`
+}
+
+type LinkGroup struct {
+	Description string      `xml:"description,attr"`
+	IssueLink   []IssueLink `xml:"issuelink"`
+}
+
+type IssueLink struct {
+	IssueKey IssueKey `xml:"issuekey"`
+}
+
+type IssueKey struct {
+	ID    string `xml:"id,attr"`
+	Value string `xml:",chardata"`
+}
+
+type Subtasks struct {
+	Subtask []IssueKey `xml:"subtask"`
+}
+
+// relatedIssuesSection lists the parent, subtasks and linked issues of item,
+// the links grouped by their direction's description ("blocks", "is blocked
+// by"). Empty groups are left out.
+func relatedIssuesSection(item Item, config Config) section {
+	sec := section{id: "related_issues", heading: config.label("related_issues")}
+	add := func(label string, keys []string) {
+		var links []string
+		for _, key := range keys {
+			if key = strings.TrimSpace(key); key != "" {
+				links = append(links, issueLink(key, config))
+			}
+		}
+		if len(links) > 0 {
+			sec.fields = append(sec.fields, field{label, strings.Join(links, ", ")})
+		}
+	}
+	keys := func(links []IssueLink) []string {
+		var keys []string
+		for _, link := range links {
+			keys = append(keys, link.IssueKey.Value)
+		}
+		return keys
+	}
+
+	add(config.label("parent"), []string{item.Parent.Value})
+	var subtasks []string
+	for _, subtask := range item.Subtasks.Subtask {
+		subtasks = append(subtasks, subtask.Value)
+	}
+	add(config.label("subtasks"), subtasks)
+
+	for _, lt := range item.IssueLinks.IssueLinkType {
+		add(firstNonEmpty(lt.OutwardLinks.Description, lt.Name), keys(lt.OutwardLinks.IssueLink))
+		add(firstNonEmpty(lt.InwardLinks.Description, lt.Name), keys(lt.InwardLinks.IssueLink))
+	}
+
+	return sec
+}
+
+// RemoteLinks are links from an issue to pages outside JIRA, such as
+// Confluence pages or pull requests. Exports give the details either as
+// attributes or as child elements.
+type RemoteLinks struct {
+	RemoteLink []RemoteLink `xml:"remotelink"`
+}
+
+type RemoteLink struct {
+	ID               string `xml:"id,attr"`
+	TitleAttr        string `xml:"title,attr"`
+	URLAttr          string `xml:"url,attr"`
+	RelationshipAttr string `xml:"relationship,attr"`
+	Title            string `xml:"title"`
+	URL              string `xml:"url"`
+	Relationship     string `xml:"relationship"`
+}
+
+// remoteLinksSection lists the remote links of item as Markdown links, or
+// returns a section with an empty body when there are none.
+func remoteLinksSection(item Item, config Config) section {
+	var body strings.Builder
+	for _, link := range item.RemoteLinks.RemoteLink {
+		url := strings.TrimSpace(firstNonEmpty(link.URL, link.URLAttr))
+		if url == "" {
+			continue
+		}
+		title := strings.TrimSpace(firstNonEmpty(link.Title, link.TitleAttr, url))
+		body.WriteString("- ")
+		if relationship := strings.TrimSpace(firstNonEmpty(link.Relationship, link.RelationshipAttr)); relationship != "" {
+			fmt.Fprintf(&body, "%s: ", relationship)
+		}
+		fmt.Fprintf(&body, "[%s](%s)\n", strings.ReplaceAll(title, "]", "\\]"), url)
+	}
+	return section{id: "related_links", heading: config.label("related_links"), body: body.String()}
+}
+
+// firstNonEmpty returns the first of values that isn't blank.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// blockingEdges returns the blocks/is-blocked-by relationships of item as
+// [from, to] pairs, where from blocks to. Each edge is returned once, even
+// when the export lists it in both directions or an issue links to itself,
+// so cyclic link graphs stay finite.
+func blockingEdges(item Item) [][2]string {
+	var edges [][2]string
+	seen := make(map[[2]string]bool)
+	add := func(from, to string) {
+		edge := [2]string{from, to}
+		if from == "" || to == "" || seen[edge] {
+			return
+		}
+		seen[edge] = true
+		edges = append(edges, edge)
+	}
+
+	for _, lt := range item.IssueLinks.IssueLinkType {
+		if !strings.EqualFold(lt.Name, "Blocks") {
+			continue
+		}
+		for _, link := range lt.OutwardLinks.IssueLink {
+			add(item.Key.Value, link.IssueKey.Value)
+		}
+		for _, link := range lt.InwardLinks.IssueLink {
+			add(link.IssueKey.Value, item.Key.Value)
+		}
+	}
+	return edges
+}
+
+// mermaidDeps renders the blocking relationships of item as a Mermaid graph,
+// or returns "" when there is nothing to draw.
+func mermaidDeps(item Item) string {
+	edges := blockingEdges(item)
+	if len(edges) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("```mermaid\ngraph TD\n")
+	for _, edge := range edges {
+		fmt.Fprintf(&sb, "    %s --> %s\n", mermaidNode(edge[0]), mermaidNode(edge[1]))
+	}
+	sb.WriteString("```\n")
+
+	return sb.String()
+}
+
+// mermaidNode returns a node declaration with a Mermaid-safe ID and the issue
+// key as its label.
+func mermaidNode(key string) string {
+	id := strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, key)
+	return fmt.Sprintf("%s[\"%s\"]", id, key)
+}
+
+// issueURL returns the browse URL for an issue key, or "" when the export
+// gives no usable JIRA host to link to. With --local-links, keys converted in
+// this run link to their output file relative to the current one instead.
+func issueURL(key string, config Config) string {
+	if path, ok := config.localPaths[key]; ok && config.outputFile != "" {
+		if rel, err := filepath.Rel(filepath.Dir(config.outputFile), path); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	if config.baseURL != "" {
+		return strings.TrimSuffix(config.baseURL, "/") + "/" + key
+	}
+	if config.channelLink == "" {
+		return ""
+	}
+	return strings.TrimSuffix(config.channelLink, "/") + "/browse/" + key
+}
+
+// publicURL rewrites a JIRA browse URL such as https://jira.internal/browse/AI-538
+// to the --base-url, keeping the issue key and any query or fragment. Other
+// URLs, and all URLs when no base URL is set, are returned unchanged.
+func publicURL(link string, config Config) string {
+	if config.baseURL == "" {
+		return link
+	}
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return link
+	}
+	i := strings.LastIndex(u.Path, "/browse/")
+	if i == -1 {
+		return link
+	}
+	key := strings.Trim(u.Path[i+len("/browse/"):], "/")
+	if key == "" || strings.Contains(key, "/") {
+		return link
+	}
+
+	public := strings.TrimSuffix(config.baseURL, "/") + "/" + key
+	if u.RawQuery != "" {
+		public += "?" + u.RawQuery
+	}
+	if u.Fragment != "" {
+		public += "#" + u.EscapedFragment()
+	}
+	return public
+}
+
+// publicBodyLinks rewrites the browse URLs in converted Markdown that point
+// at the JIRA instance of the export to the --base-url. Links to other hosts
+// and code blocks are left alone.
+func publicBodyLinks(md string, config Config) string {
+	jira, err := url.Parse(config.channelLink)
+	if config.baseURL == "" || err != nil || jira.Host == "" {
+		return md
+	}
+
+	return mapOutsideFences(md, func(line string) string {
+		return httpURLPattern.ReplaceAllStringFunc(line, func(link string) string {
+			if u, err := url.Parse(link); err != nil || !strings.EqualFold(u.Host, jira.Host) {
+				return link
+			}
+			return publicURL(link, config)
+		})
+	})
+}
+
+// issueLink renders key as a Markdown link, falling back to the bare key when
+// no URL can be built for it.
+func issueLink(key string, config Config) string {
+	if url := issueURL(key, config); url != "" {
+		return fmt.Sprintf("[%s](%s)", key, url)
+	}
+	return key
+}
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+// linkCycleFixture holds two issues that block each other, each side
+// listing the link in both directions, and a third that blocks itself.
+const linkCycleFixture = `<item><key>AI-1</key><issuelinks><issuelinktype><name>Blocks</name>
+<outwardlinks description="blocks"><issuelink><issuekey>AI-2</issuekey></issuelink></outwardlinks>
+<inwardlinks description="is blocked by"><issuelink><issuekey>AI-2</issuekey></issuelink></inwardlinks>
+</issuelinktype></issuelinks></item>
+<item><key>AI-2</key><issuelinks><issuelinktype><name>Blocks</name>
+<outwardlinks description="blocks"><issuelink><issuekey>AI-1</issuekey></issuelink><issuelink><issuekey>AI-1</issuekey></issuelink></outwardlinks>
+<inwardlinks description="is blocked by"><issuelink><issuekey>AI-1</issuekey></issuelink></inwardlinks>
+</issuelinktype></issuelinks></item>
+<item><key>AI-3</key><issuelinks><issuelinktype><name>Blocks</name>
+<outwardlinks description="blocks"><issuelink><issuekey>AI-3</issuekey></issuelink></outwardlinks>
+<inwardlinks description="is blocked by"><issuelink><issuekey>AI-3</issuekey></issuelink></inwardlinks>
+</issuelinktype></issuelinks></item>`
+
+func TestMermaidDepsLinkCycle(t *testing.T) {
+	items := map[string]Item{}
+	for _, item := range testItems(t, testConfig(t), linkCycleFixture) {
+		items[item.Key.Value] = item
+	}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{
+			key:  "AI-1",
+			want: "```mermaid\ngraph TD\n    AI_1[\"AI-1\"] --> AI_2[\"AI-2\"]\n    AI_2[\"AI-2\"] --> AI_1[\"AI-1\"]\n```\n",
+		},
+		{
+			key:  "AI-2",
+			want: "```mermaid\ngraph TD\n    AI_2[\"AI-2\"] --> AI_1[\"AI-1\"]\n    AI_1[\"AI-1\"] --> AI_2[\"AI-2\"]\n```\n",
+		},
+		{
+			key:  "AI-3",
+			want: "```mermaid\ngraph TD\n    AI_3[\"AI-3\"] --> AI_3[\"AI-3\"]\n```\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := mermaidDeps(items[tt.key]); got != tt.want {
+				t.Errorf("mermaidDeps(%s) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// lintMarkdown parses generated Markdown and returns structural warnings that
+// point at bugs in our own transforms: unterminated code fences, pipe rows
+// that did not parse as a table, and link syntax that did not parse as a link.
+func lintMarkdown(md string) []string {
+	var warnings []string
+
+	// Fences are checked line by line; an unterminated fence silently
+	// swallows the rest of the document, so the parser won't complain
+	if line := unterminatedFence(md); line > 0 {
+		warnings = append(warnings, fmt.Sprintf("line %d: unterminated code fence", line))
+	}
+
+	source := []byte(md)
+	parser := goldmark.New(goldmark.WithExtensions(extension.Table)).Parser()
+	doc := parser.Parse(text.NewReader(source))
+
+	lineOf := func(offset int) int {
+		return bytes.Count(source[:offset], []byte("\n")) + 1
+	}
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch node := n.(type) {
+		case *ast.Paragraph:
+			lines := node.Lines()
+			if lines.Len() > 0 {
+				first := lines.At(0)
+				row := strings.TrimSpace(string(first.Value(source)))
+				if strings.HasPrefix(row, "|") && strings.Count(row, "|") > 1 {
+					warnings = append(warnings, fmt.Sprintf("line %d: malformed table", lineOf(first.Start)))
+				}
+			}
+		case *ast.Text:
+			segment := node.Segment
+			if strings.Contains(string(segment.Value(source)), "](") {
+				warnings = append(warnings, fmt.Sprintf("line %d: broken link syntax", lineOf(segment.Start)))
+			}
+		case *ast.FencedCodeBlock, *ast.CodeBlock, *ast.CodeSpan, *ast.HTMLBlock, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return warnings
+}
+
+// unterminatedFence returns the line number of a code fence that is never
+// closed, or 0 if every fence is closed.
+func unterminatedFence(md string) int {
+	open := ""
+	openLine := 0
+
+	for i, line := range strings.Split(md, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if len(line)-len(trimmed) > 3 {
+			continue
+		}
+
+		marker := fenceMarker(trimmed)
+		switch {
+		case open == "" && marker != "":
+			open, openLine = marker, i+1
+		case open != "" && marker != "" && marker[0] == open[0] && len(marker) >= len(open) &&
+			strings.TrimSpace(trimmed[len(marker):]) == "":
+			open = ""
+		}
+	}
+
+	if open != "" {
+		return openLine
+	}
+	return 0
+}
+
+// fenceMarker returns the run of ``` or ~~~ (three or more) starting line,
+// or "" if line doesn't start with a fence.
+func fenceMarker(line string) string {
+	if len(line) < 3 || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	n := 0
+	for n < len(line) && line[n] == line[0] {
+		n++
+	}
+	if n < 3 {
+		return ""
+	}
+	return line[:n]
+}
@@ -1,13 +1,22 @@
 package main
 
 import (
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"html"
+	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	pflag "github.com/spf13/pflag"
+	"golang.org/x/text/encoding/htmlindex"
 )
 
 const version = "1.0.0"
@@ -22,27 +31,42 @@ type Channel struct {
 	Items []Item `xml:"item"`
 }
 
+
 type Item struct {
-	Title        string       `xml:"title"`
-	Link         string       `xml:"link"`
-	Key          Key          `xml:"key"`
-	Summary      string       `xml:"summary"`
-	Type         TypeField    `xml:"type"`
-	Priority     Priority     `xml:"priority"`
-	Status       Status       `xml:"status"`
-	Resolution   Resolution   `xml:"resolution"`
-	Assignee     string       `xml:"assignee"`
-	Reporter     string       `xml:"reporter"`
-	Labels       Labels       `xml:"labels"`
-	Components   Components   `xml:"component"`
-	Versions     Versions     `xml:"version"`
-	Description  string       `xml:"description"`
-	Created      string       `xml:"created"`
-	Updated      string       `xml:"updated"`
-	Due          string       `xml:"due"`
-	Comments     Comments     `xml:"comments"`
-	Attachments  Attachments  `xml:"attachments"`
-	CustomFields CustomFields `xml:"customfields"`
+	Title          string         `xml:"title"`
+	Link           string         `xml:"link"`
+	Key            Key            `xml:"key"`
+	Summary        string         `xml:"summary"`
+	Type           TypeField      `xml:"type"`
+	Priority       Priority       `xml:"priority"`
+	Status         Status         `xml:"status"`
+	StatusCategory StatusCategory `xml:"statusCategory"`
+	Resolution     Resolution     `xml:"resolution"`
+	Assignee       User           `xml:"assignee"`
+	Reporter       User           `xml:"reporter"`
+	Labels         Labels         `xml:"labels"`
+	Components     Components     `xml:"component"`
+	Versions       Versions       `xml:"version"`
+	Description    string         `xml:"description"`
+	Environment    string         `xml:"environment"`
+	RenderedBody   string         `xml:"renderedBody"`
+	Created        string         `xml:"created"`
+	Updated        string         `xml:"updated"`
+	Resolved       string         `xml:"resolved"`
+	Due            string         `xml:"due"`
+	Votes          string         `xml:"votes"`
+	Comments       Comments       `xml:"comments"`
+	Attachments    Attachments    `xml:"attachments"`
+	CustomFields   CustomFields   `xml:"customfields"`
+	IssueLinks     IssueLinks     `xml:"issuelinks"`
+	Parent         IssueKey       `xml:"parent"`
+	Subtasks       Subtasks       `xml:"subtasks"`
+	RemoteLinks    RemoteLinks    `xml:"remotelinks"`
+	Worklogs       Worklogs       `xml:"worklogs"`
+
+	TimeOriginalEstimate TimeValue `xml:"timeoriginalestimate"`
+	TimeEstimate         TimeValue `xml:"timeestimate"`
+	TimeSpent            TimeValue `xml:"timespent"`
 }
 
 type Key struct {
@@ -63,9 +87,23 @@ type Priority struct {
 }
 
 type Status struct {
-	ID      string `xml:"id,attr"`
-	IconURL string `xml:"iconUrl,attr"`
-	Value   string `xml:",chardata"`
+	ID       string `xml:"id,attr"`
+	IconURL  string `xml:"iconUrl,attr"`
+	Category string `xml:"statusCategory,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type StatusCategory struct {
+	ID        string `xml:"id,attr"`
+	Key       string `xml:"key,attr"`
+	ColorName string `xml:"colorName,attr"`
+	Value     string `xml:",chardata"`
+}
+
+type User struct {
+	Username  string `xml:"username,attr"`
+	AvatarURL string `xml:"avatarUrl,attr"`
+	Value     string `xml:",chardata"`
 }
 
 type Resolution struct {
@@ -96,6 +134,19 @@ type Comment struct {
 	Value   string `xml:",chardata"`
 }
 
+type Worklogs struct {
+	Worklog []Worklog `xml:"worklog"`
+}
+
+type Worklog struct {
+	ID          string `xml:"id,attr"`
+	Author      string `xml:"author,attr"`
+	Created     string `xml:"created,attr"`
+	TimeStarted string `xml:"timeStarted,attr"`
+	TimeSpent   string `xml:"timeSpent,attr"`
+	Comment     string `xml:",chardata"`
+}
+
 type Attachments struct {
 	Attachment []Attachment `xml:"attachment"`
 }
@@ -126,15 +177,145 @@ type CustomFieldValues struct {
 type CustomFieldValue struct {
 	Key   string `xml:"key,attr"`
 	Value string `xml:",chardata"`
+	Inner string `xml:",innerxml" json:"-"`
 }
 
 type Config struct {
-	inputFiles []string
-	output     string
-	details    bool
-	verbose    bool
-	force      bool
-	showVersion bool
+	inputFiles       []string
+	output           string
+	detailsFlag      string
+	details          bool
+	verbose          bool
+	force            bool
+	showVersion      bool
+	watermark        string
+	baseline         string
+	imagePlaceholder string
+	labelsFile       string
+	mermaidDeps      bool
+	commentsDir      string
+	inputEncoding    string
+	manifest         string
+	statusEmoji      bool
+	onlyKeys         []string
+	inputFormat      string
+	lint             bool
+	strict           bool
+	serve            string
+	onCollision      string
+	compact          bool
+	emitEmptyFile    bool
+	timeline         bool
+	localLinks       bool
+	dedupeComments   bool
+	progress         bool
+	avatars          bool
+	typeBadge        bool
+	maxFileSize      string
+	maxFileBytes     int64
+	referenceLinks   bool
+	doctor           bool
+	statusCategory   bool
+	noLinkLine       bool
+	sinceComments    string
+	sinceCommentsAt  time.Time
+	commentAnchors   bool
+	appendFields     bool
+	preferRendered   bool
+	outputEOL        string
+	outputBOM        bool
+	inlineImages     bool
+	inlineImageMax   string
+	inlineImageBytes int64
+	dumpParsed       bool
+	multivalueStyle  string
+	fallbackField    string
+	normalizeKeys    bool
+	badges           bool
+	badgeURL         string
+	keepTrailingWS   bool
+	keepTags         []string
+	headingOffset    int
+	redactPatterns   []string
+	redactions       []redaction
+	assumeYes        bool
+	codeLineNumbers  bool
+	index            string
+	sortIndexBy      string
+	outputPerms      string
+	fileMode         os.FileMode
+	resume           bool
+	stateFile        string
+	ndjson           bool
+	fieldRenderMap   string
+	fieldRenders     map[string]string
+	noOverview       bool
+	priorityOrder    []string
+	priorityRanks    map[string]int
+	minPriority      string
+	attachmentsDir   string
+	summaryField     string
+	csv              string
+	stripMacros      bool
+	activityDigest   int
+	frontMatter      bool
+	format           string
+	checkLinks       bool
+	preset           string
+	presetsFile      string
+	presets          map[string][]string
+	template         string
+	tmpl             *template.Template
+	jobs             int
+	failFast         bool
+	callouts         bool
+	keepOriginal     bool
+	dateFormat       string
+	dateLayout       string
+	downloadImages   string
+	includeFields    []string
+	excludeFields    []string
+	icons            bool
+	iconsFile        string
+	statusIcons      map[string]string
+	priorityIcons    map[string]string
+	toc              bool
+	combine          bool
+	append           bool
+	dryRun           bool
+	baseURL          string
+	publicBodyLinks  bool
+	userMap          string
+	users            map[string]string
+
+	baselineIssues map[string]BaselineIssue
+	labels         map[string]string
+
+	// localPaths maps the key of every item converted in this run to its
+	// output file, for --local-links
+	localPaths map[string]string
+
+	// inputFile is the file the current items are converted from
+	inputFile string
+
+	// outputFile is the file the current item is being written to
+	outputFile string
+
+	// attachmentPaths maps the ids of the current item's attachments copied
+	// by --attachments-dir to their paths relative to the output file
+	attachmentPaths map[string]string
+
+	// imagePaths maps the src of the current item's images saved by
+	// --download-images to their paths relative to the output file
+	imagePaths map[string]string
+
+	// commentsLinkDir is the --comments-dir path relative to the output file
+	// currently being written, used to link to the per-comment files
+	commentsLinkDir string
+
+	// channelLink is the JIRA base URL of the export currently being
+	// converted, used to link bare issue keys
+	channelLink string
 }
 
 func main() {
@@ -145,29 +326,157 @@ func main() {
 		os.Exit(0)
 	}
 
+	if config.doctor {
+		if !runDoctor(os.Stdout) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if err := prepareConfig(&config); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if config.serve != "" {
+		if err := serve(config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if len(config.inputFiles) == 0 {
 		fmt.Fprintln(os.Stderr, "Error: no input files specified")
 		pflag.Usage()
 		os.Exit(1)
 	}
 
-	for _, inputFile := range config.inputFiles {
-		if err := processFile(inputFile, config); err != nil {
-			fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", inputFile, err)
+	state := &runState{}
+	if config.localLinks || (config.jobs > 1 && len(config.inputFiles) > 1) {
+		paths := planOutputs(config, state)
+		if config.localLinks {
+			config.localPaths = paths
+		}
+	}
+
+	if config.ndjson {
+		failed := 0
+		for _, inputFile := range config.inputFiles {
+			if err := streamNDJSON(inputFile, os.Stdout, config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", inputFile, err)
+				failed++
+				if config.failFast {
+					break
+				}
+			}
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	var prog *progress
+	if config.progress {
+		prog = newProgress(len(config.inputFiles))
+	}
+
+	var resume *resumeState
+	if config.resume {
+		var err error
+		if resume, err = loadResumeState(config.stateFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if config.combine && config.output != "-" && !config.append && !config.dryRun {
+		if _, err := os.Stat(config.output); err == nil && !mayOverwrite(config.output, config) {
+			fmt.Fprintf(os.Stderr, "Error: output file %s already exists (use -f to overwrite, or --append)\n", config.output)
+			os.Exit(1)
+		}
+	}
+
+	errs := processFiles(config, state, prog, resume)
+	if prog != nil {
+		prog.finish()
+	}
+	if len(errs) > 0 && config.failFast {
+		os.Exit(1)
+	}
+
+	if config.combine {
+		if err := writeCombined(config, state); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else if len(state.combined) > 0 {
+		stdout := config
+		stdout.output = "-"
+		if err := writeCombined(stdout, state); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if config.dryRun {
+		for _, path := range []string{config.csv, config.index, config.manifest} {
+			if path != "" {
+				reportDryRun("summary", path, -1, config)
+			}
+		}
+		if len(errs) > 0 {
+			fmt.Fprintf(os.Stderr, "Error: %d of %d file(s) failed\n", len(errs), len(config.inputFiles))
+			os.Exit(1)
+		}
+		return
+	}
+
+	sortRunOrder(state.index)
+
+	if config.csv != "" {
+		if err := writeCSV(config.csv, state.index, config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		state.outputs = append(state.outputs, config.csv)
+		if config.verbose {
+			fmt.Fprintf(os.Stderr, "Created %s\n", config.csv)
+		}
+	}
+
+	if config.index != "" {
+		if err := writeIndex(config.index, state.index, config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		state.outputs = append(state.outputs, config.index)
+		if config.verbose {
+			fmt.Fprintf(os.Stderr, "Created %s\n", config.index)
+		}
+	}
+
+	if config.manifest != "" {
+		if err := writeManifest(config.manifest, state.outputs, config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+		if config.verbose {
+			fmt.Fprintf(os.Stderr, "Created %s\n", config.manifest)
+		}
+	}
+
+	// The index, CSV and manifest above cover the files that did convert
+	if len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: %d of %d file(s) failed\n", len(errs), len(config.inputFiles))
+		os.Exit(1)
 	}
 }
 
 func parseFlags() Config {
 	config := Config{}
-
-	var detailsStr string
-	pflag.StringVarP(&config.output, "output", "o", "", "Output file path (defaults to *.details.md or *.md)")
-	pflag.StringVarP(&detailsStr, "details", "d", "enabled", "Include custom fields details (on|off|enabled|disabled|1|0)")
-	pflag.BoolVarP(&config.verbose, "verbose", "v", false, "Verbose output")
-	pflag.BoolVarP(&config.force, "force", "f", false, "Force overwrite existing files")
-	pflag.BoolVar(&config.showVersion, "version", false, "Show version")
+	defineFlags(pflag.CommandLine, &config)
 
 	pflag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] FILE [FILE...]\n\n", os.Args[0])
@@ -179,362 +488,1694 @@ func parseFlags() Config {
 		fmt.Fprintf(os.Stderr, "  %s --output output.md AI-538.xml\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s --details off AI-538.xml\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s *.xml\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  curl ... | %s - | less\n", os.Args[0])
 	}
 
 	pflag.Parse()
 
 	config.inputFiles = pflag.Args()
-	config.details = parseDetailsFlag(detailsStr)
 
 	return config
 }
 
-func parseDetailsFlag(s string) bool {
-	switch strings.ToLower(s) {
-	case "on", "enabled", "1", "true", "yes":
-		return true
-	case "off", "disabled", "0", "false", "no":
-		return false
-	default:
-		return true // default to enabled
+// defineFlags registers every option on fs, bound to the fields of config.
+// The HTTP service uses it to parse request query parameters the same way.
+func defineFlags(fs *pflag.FlagSet, config *Config) {
+	fs.StringVarP(&config.output, "output", "o", "", "Output file path, directory, or - for stdout (defaults to *.details.md or *.md, or stdout for stdin input)")
+	fs.StringVarP(&config.detailsFlag, "details", "d", "enabled", "Include custom fields details (on|off|enabled|disabled|1|0)")
+	fs.BoolVarP(&config.verbose, "verbose", "v", false, "Verbose output")
+	fs.BoolVarP(&config.force, "force", "f", false, "Force overwrite existing files")
+	fs.StringVar(&config.watermark, "watermark", "", "Insert a prominent banner (e.g. \"DRAFT — INTERNAL ONLY\") at the top of each file")
+	fs.StringVar(&config.baseline, "baseline", "", "Emit only what changed relative to a previous XML export or generated Markdown file")
+	fs.StringVar(&config.imagePlaceholder, "image-placeholder", "Image", "Alt text used for images and attachments without a name")
+	fs.StringVar(&config.labelsFile, "labels-file", "", "JSON file overriding section headings and field labels")
+	fs.BoolVar(&config.mermaidDeps, "mermaid-deps", false, "Render blocks/is-blocked-by links as a Mermaid diagram (experimental)")
+	fs.StringVar(&config.commentsDir, "comments-dir", "", "Also write each comment to DIR/KEY-comment-<id>.md")
+	fs.StringVar(&config.inputEncoding, "input-encoding", "", "Character encoding of the input (e.g. windows-1252); detected from the XML declaration by default")
+	fs.StringVar(&config.manifest, "manifest", "", "Write a SHA-256 manifest of all output files (sha256sum -c compatible)")
+	fs.BoolVar(&config.statusEmoji, "status-emoji", false, "Prefix the resolution with ✅ (resolved) or ❌ (unresolved), and the status with an emoji for its category")
+	fs.StringSliceVar(&config.onlyKeys, "only-keys", nil, "Only convert items with these issue keys (comma-separated, case-insensitive)")
+	fs.StringVar(&config.inputFormat, "input-format", "html", "Markup used in descriptions and comments (html|wiki|auto)")
+	fs.BoolVar(&config.lint, "lint", false, "Check the generated Markdown for structural problems and report them on stderr")
+	fs.BoolVar(&config.strict, "strict", false, "Treat --lint warnings and --check-links failures as errors")
+	fs.StringVar(&config.serve, "serve", "", "Run an HTTP conversion service on ADDR (e.g. :8080) instead of converting files")
+	fs.StringVar(&config.onCollision, "on-collision", "error", "What to do when two items in one run map to the same output file (suffix|error|overwrite)")
+	fs.BoolVar(&config.compact, "compact", false, "Minimize vertical space: inline labels instead of section headings, fields on one line")
+	fs.BoolVar(&config.emitEmptyFile, "emit-empty-file", false, "Write a stub file for items excluded by filters instead of skipping them")
+	fs.BoolVar(&config.timeline, "timeline", false, "Add a Timeline section listing all dates in chronological order")
+	fs.BoolVar(&config.localLinks, "local-links", false, "Link issue keys converted in the same run to their output files instead of JIRA")
+	fs.BoolVar(&config.dedupeComments, "dedupe-comments", false, "Drop comments that repeat an earlier comment by the same author within a few minutes")
+	fs.BoolVar(&config.progress, "progress", false, "Print a processed N/M line to stderr while converting")
+	fs.BoolVar(&config.avatars, "avatars", false, "Show assignee and reporter avatars in the Overview when the export has them")
+	fs.BoolVar(&config.typeBadge, "type-badge", false, "Show the issue type icon next to the type in the Overview")
+	fs.StringVar(&config.maxFileSize, "max-file-size", "", "Refuse input files larger than this size, e.g. 500K, 10M, 1G (default unlimited)")
+	fs.BoolVar(&config.referenceLinks, "reference-links", false, "Collect links into numbered references at the end of the document")
+	fs.BoolVar(&config.doctor, "doctor", false, "Run a self-test on a built-in sample and report environment details")
+	fs.BoolVar(&config.statusCategory, "status-category", false, "Show the status category after the status, e.g. In Review (In Progress)")
+	fs.BoolVar(&config.noLinkLine, "no-link-line", false, "Omit the Link line under the title")
+	fs.StringVar(&config.sinceComments, "since-comments", "", "Only show comments created on or after this date (e.g. 2024-06-01)")
+	fs.BoolVar(&config.commentAnchors, "comment-anchors", false, "Add an anchor and JIRA permalink to each comment")
+	fs.BoolVar(&config.appendFields, "append-fields-to-details", false, "Append custom fields to the end of the Details section instead of a separate section")
+	fs.BoolVar(&config.preferRendered, "prefer-rendered-body", false, "Use JIRA's pre-rendered HTML description when the export includes one")
+	fs.StringVar(&config.outputEOL, "output-eol", "lf", "Line endings of the written Markdown: lf or crlf")
+	fs.BoolVar(&config.outputBOM, "output-bom", false, "Start the written Markdown with a UTF-8 byte order mark")
+	fs.BoolVar(&config.inlineImages, "inline-images", false, "Download images and embed them as base64 data URIs")
+	fs.StringVar(&config.inlineImageMax, "inline-image-max", "256K", "Largest image to embed with --inline-images; bigger ones stay links")
+	fs.BoolVar(&config.dumpParsed, "dump-parsed", false, "Print each parsed item as JSON to stderr before converting it")
+	fs.StringVar(&config.multivalueStyle, "multivalue-style", "comma", "How to show multi-value custom fields: comma, bullets or newline")
+	fs.StringVar(&config.fallbackField, "description-fallback-field", "", "Custom field to show when the description is empty (default: the first long text field)")
+	fs.BoolVar(&config.normalizeKeys, "normalize-keys", false, "Trim and uppercase issue keys before using them in titles, file names and links")
+	fs.BoolVar(&config.badges, "badges", false, "Add status, priority and type badges under the title")
+	fs.StringVar(&config.badgeURL, "badge-url", "https://img.shields.io/badge", "Base URL of the shields.io-compatible badge service used by --badges")
+	fs.BoolVar(&config.keepTrailingWS, "keep-trailing-whitespace", false, "Don't strip trailing whitespace from output lines")
+	fs.StringSliceVar(&config.keepTags, "keep-tags", nil, "HTML tags to keep as raw HTML instead of converting (comma-separated, e.g. kbd,sup)")
+	fs.IntVar(&config.headingOffset, "heading-offset", 0, "Shift headings from the issue body down this many levels")
+	fs.StringArrayVar(&config.redactPatterns, "redact-pattern", nil, "Replace matches of this regular expression with [REDACTED], or with REPLACEMENT given as REGEX=>REPLACEMENT (repeatable)")
+	fs.BoolVarP(&config.assumeYes, "assume-yes", "y", false, "Overwrite existing files without asking")
+	fs.BoolVar(&config.codeLineNumbers, "code-line-numbers", false, "Number the lines of code blocks longer than one line")
+	fs.StringVar(&config.index, "index", "", "Write a Markdown index table linking to every converted item to this file")
+	fs.StringVar(&config.sortIndexBy, "sort-index-by", "key", "Order of the --index table: key, status, priority, votes or updated")
+	fs.StringVar(&config.outputPerms, "output-permissions", "", "Octal mode for written files, e.g. 0640; directories get matching execute bits (default 0644)")
+	fs.BoolVar(&config.resume, "resume", false, "Skip inputs that were converted by an earlier --resume run and haven't changed since")
+	fs.StringVar(&config.stateFile, "state-file", ".converttomd-jira-state.json", "File where --resume records converted inputs")
+	fs.BoolVar(&config.ndjson, "ndjson", false, "Stream each item to stdout as one line of JSON instead of writing Markdown files")
+	fs.StringVar(&config.fieldRenderMap, "field-render-map", "", "JSON file mapping custom field names, ids or type keys to text, markdown, link, date, user, bullets or table")
+	fs.BoolVar(&config.noOverview, "no-overview", false, "Omit the Overview section; the key, status and other overview fields go into YAML front matter instead")
+	fs.StringSliceVar(&config.priorityOrder, "priority-order", nil, "Priority names from most to least urgent, used for sorting and --min-priority (comma-separated; default Blocker/Highest, Critical/High, Major/Medium, Minor/Low, Trivial/Lowest)")
+	fs.StringVar(&config.minPriority, "min-priority", "", "Skip items less urgent than this priority, e.g. Major")
+	fs.StringVar(&config.attachmentsDir, "attachments-dir", "", "Copy attachment files from this directory of a JIRA full export next to the output, and link to the copies")
+	fs.StringVar(&config.summaryField, "summary-field", "", "Custom field (name or id) to use as the document title instead of the summary, when it has a value")
+	fs.StringVar(&config.csv, "csv", "", "Write a CSV summary of every converted item (key, summary, type, status, priority, assignee, reporter, created, updated) to this file")
+	fs.BoolVar(&config.stripMacros, "strip-unknown-macros", false, "Remove the braces of {macro} and {macro:params} markup that has no conversion, keeping the content")
+	fs.IntVar(&config.activityDigest, "activity-digest", 0, "Add a Recent Activity section with the N newest comments and work log entries")
+	fs.BoolVar(&config.checkLinks, "check-links", false, "Check that every HTTP(S) link in the output resolves and report broken ones on stderr (needs network access)")
+	fs.StringVar(&config.preset, "preset", "", "Section layout to use: bug, story, epic, a name from --presets-file, or auto to pick by issue type")
+	fs.StringVar(&config.presetsFile, "presets-file", "", "JSON file defining extra --preset layouts as lists of section ids")
+	fs.BoolVar(&config.frontMatter, "front-matter", false, "Start each file with YAML front matter (title, key, status, priority, people, dates, labels) for static site generators")
+	fs.StringVar(&config.format, "format", "md", "Output format: md, or json for the parsed issue as indented JSON")
+	fs.StringVar(&config.template, "template", "", "Go text/template file used to lay out each item instead of the built-in layout")
+	fs.IntVarP(&config.jobs, "jobs", "j", runtime.NumCPU(), "Number of input files to convert at once")
+	fs.BoolVar(&config.failFast, "fail-fast", false, "Stop at the first input file that fails instead of converting the rest")
+	fs.BoolVar(&config.callouts, "callouts", false, "Render info, tip, note and warning panels as GitHub alerts (> [!NOTE]) instead of labelled blockquotes")
+	fs.BoolVar(&config.keepOriginal, "keep-original", false, "Append the description's original HTML in a collapsed block, to check the conversion against")
+	fs.StringVar(&config.dateFormat, "date-format", "", "Reformat dates with a Go time layout or a preset (iso, date-only, datetime, rfc1123)")
+	fs.StringVar(&config.downloadImages, "download-images", "", "Save embedded images into this directory and link to the local copies")
+	fs.StringSliceVar(&config.includeFields, "include-fields", nil, "Only list these custom fields in Custom Fields (comma-separated names, case-insensitive)")
+	fs.StringSliceVar(&config.excludeFields, "exclude-fields", nil, "Leave these custom fields out of Custom Fields (comma-separated names, case-insensitive); wins over --include-fields")
+	fs.BoolVar(&config.icons, "icons", false, "Prefix the status and priority in the Overview with an emoji for common values")
+	fs.StringVar(&config.iconsFile, "icons-file", "", "JSON file overriding the --icons emoji: {\"status\": {...}, \"priority\": {...}}")
+	fs.BoolVar(&config.toc, "toc", false, "Start the document with a Contents section linking to each section")
+	fs.BoolVar(&config.combine, "combine", false, "Write the items of all input files into the single -o file, in the order given")
+	fs.BoolVar(&config.append, "append", false, "Add documents to the end of existing output files instead of replacing them (takes precedence over --force)")
+	fs.BoolVarP(&config.dryRun, "dry-run", "n", false, "Convert the input but only print which files would be created or overwritten")
+	fs.StringVar(&config.baseURL, "base-url", "", "Point issue links at this base instead of the export's JIRA host (e.g. https://public.example.com/browse)")
+	fs.BoolVar(&config.publicBodyLinks, "base-url-in-body", false, "Also rewrite links to the export's JIRA issues in descriptions and comments to --base-url")
+	fs.StringVar(&config.userMap, "user-map", "", "JSON file mapping JIRA usernames to the names shown for mentions and comment authors")
+	fs.BoolVar(&config.showVersion, "version", false, "Show version")
+}
+
+// prepareConfig resolves option values, validates them and loads any files
+// they refer to.
+func prepareConfig(config *Config) error {
+	if err := resolveConfig(config); err != nil {
+		return err
 	}
+	return loadConfigFiles(config)
 }
 
-func processFile(inputFile string, config Config) error {
-	if config.verbose {
-		fmt.Printf("Processing %s...\n", inputFile)
+// resolveConfig resolves and validates option values without reading any
+// files.
+func resolveConfig(config *Config) error {
+	config.details = parseDetailsFlag(config.detailsFlag)
+
+	if config.inputFormat != "html" && config.inputFormat != "wiki" && config.inputFormat != "auto" {
+		return fmt.Errorf("invalid --input-format %q (expected html, wiki or auto)", config.inputFormat)
 	}
 
-	// Read and parse XML
-	data, err := os.ReadFile(inputFile)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+	switch config.onCollision {
+	case "suffix", "error", "overwrite":
+	default:
+		return fmt.Errorf("invalid --on-collision %q (expected suffix, error or overwrite)", config.onCollision)
 	}
 
-	var rss RSS
-	if err := xml.Unmarshal(data, &rss); err != nil {
-		return fmt.Errorf("failed to parse XML: %w", err)
+	if config.format != "md" && config.format != "json" {
+		return fmt.Errorf("invalid --format %q (expected md or json)", config.format)
 	}
 
-	if len(rss.Channel.Items) == 0 {
-		return fmt.Errorf("no items found in XML")
+	switch config.multivalueStyle {
+	case "comma", "bullets", "newline":
+	default:
+		return fmt.Errorf("invalid --multivalue-style %q (expected comma, bullets or newline)", config.multivalueStyle)
 	}
 
-	// Process each item
-	for i, item := range rss.Channel.Items {
-		// Determine output file
-		outputFile := config.output
-		if outputFile == "" {
-			base := strings.TrimSuffix(inputFile, filepath.Ext(inputFile))
-			extension := ".md"
-			if config.details {
-				extension = ".details.md"
-			}
-			
-			// If multiple items, insert issue key in filename
-			if len(rss.Channel.Items) > 1 {
-				outputFile = fmt.Sprintf("%s-%s%s", base, item.Key.Value, extension)
-			} else {
-				outputFile = base + extension
-			}
-		} else if len(rss.Channel.Items) > 1 {
-			// Custom output specified, but multiple items
-			// Insert index or issue key before extension
-			ext := filepath.Ext(outputFile)
-			base := strings.TrimSuffix(outputFile, ext)
-			if i == 0 {
-				// First item uses the specified name
-				// Subsequent items get the key inserted
-			} else {
-				outputFile = fmt.Sprintf("%s-%s%s", base, item.Key.Value, ext)
-			}
-		}
+	if config.dateFormat != "" {
+		config.dateLayout = dateLayout(config.dateFormat)
+	}
 
-		// Check if file exists
-		if !config.force {
-			if _, err := os.Stat(outputFile); err == nil {
-				return fmt.Errorf("output file %s already exists (use -f to overwrite)", outputFile)
-			}
+	if config.combine && (config.output == "" || outputIsDir(config.output)) {
+		return fmt.Errorf("--combine needs -o naming a single output file")
+	}
+	if config.combine && config.resume {
+		return fmt.Errorf("--combine can't be used with --resume, which would leave unchanged inputs out of the file")
+	}
+	if config.append && config.format == "json" {
+		return fmt.Errorf("--append can't be used with --format json, which would make the file invalid")
+	}
+
+	if config.baseURL != "" {
+		if u, err := url.Parse(config.baseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid --base-url %q: must be an absolute URL", config.baseURL)
 		}
+	}
+	if config.publicBodyLinks && config.baseURL == "" {
+		return fmt.Errorf("--base-url-in-body needs --base-url")
+	}
 
-		// Generate markdown
-		md := generateMarkdown(item, rss.Channel.Link, config.details)
+	if config.jobs < 1 {
+		return fmt.Errorf("invalid --jobs %d (expected at least 1)", config.jobs)
+	}
 
-		// Write output
-		if err := os.WriteFile(outputFile, []byte(md), 0644); err != nil {
-			return fmt.Errorf("failed to write output: %w", err)
+	if config.activityDigest < 0 {
+		return fmt.Errorf("invalid --activity-digest %d (expected a number of entries)", config.activityDigest)
+	}
+
+	switch config.sortIndexBy {
+	case "key", "status", "priority", "votes", "updated":
+	default:
+		return fmt.Errorf("invalid --sort-index-by %q (expected key, status, priority, votes or updated)", config.sortIndexBy)
+	}
+
+	if len(config.priorityOrder) > 0 {
+		ranks, err := parsePriorityOrder(config.priorityOrder)
+		if err != nil {
+			return err
 		}
+		config.priorityRanks = ranks
+	}
 
-		if config.verbose {
-			fmt.Printf("Created %s\n", outputFile)
+	if config.minPriority != "" {
+		if _, ok := knownPriorityRank(config.minPriority, *config); !ok {
+			return fmt.Errorf("invalid --min-priority %q (not in the priority order)", config.minPriority)
 		}
 	}
 
-	return nil
-}
+	if config.outputPerms != "" {
+		mode, err := strconv.ParseUint(config.outputPerms, 8, 32)
+		if err != nil || mode > 0777 {
+			return fmt.Errorf("invalid --output-permissions %q (expected an octal mode such as 0640)", config.outputPerms)
+		}
+		// A zero mode means "unset" from here on, and would leave neither the
+		// files nor the directories holding them usable anyway
+		if mode == 0 {
+			return fmt.Errorf("invalid --output-permissions %q (the files would be unreadable)", config.outputPerms)
+		}
+		config.fileMode = os.FileMode(mode)
+	}
 
-func generateMarkdown(item Item, channelLink string, includeDetails bool) string {
-	var sb strings.Builder
+	if config.outputEOL != "lf" && config.outputEOL != "crlf" {
+		return fmt.Errorf("invalid --output-eol %q (expected lf or crlf)", config.outputEOL)
+	}
 
-	// Title
-	fmt.Fprintf(&sb, "# %s: %s\n\n", item.Key.Value, item.Summary)
-	fmt.Fprintf(&sb, "**Link:** [%s](%s)\n\n", item.Link, item.Link)
+	if config.maxFileSize != "" {
+		size, err := parseSize(config.maxFileSize)
+		if err != nil {
+			return fmt.Errorf("invalid --max-file-size: %w", err)
+		}
+		config.maxFileBytes = size
+	}
 
-	// Overview
-	sb.WriteString("## Overview\n\n")
-	fmt.Fprintf(&sb, "- **Type:** %s\n", item.Type.Value)
-	fmt.Fprintf(&sb, "- **Priority:** %s\n", item.Priority.Value)
-	fmt.Fprintf(&sb, "- **Status:** %s\n", item.Status.Value)
-	fmt.Fprintf(&sb, "- **Resolution:** %s\n", item.Resolution.Value)
-	fmt.Fprintf(&sb, "- **Assignee:** %s\n", item.Assignee)
-	fmt.Fprintf(&sb, "- **Reporter:** %s\n", item.Reporter)
-	if len(item.Labels.Label) > 0 {
-		fmt.Fprintf(&sb, "- **Labels:** %s\n", strings.Join(item.Labels.Label, ", "))
+	if config.sinceComments != "" {
+		since, ok := parseJiraDate(config.sinceComments)
+		if !ok {
+			return fmt.Errorf("invalid --since-comments %q (expected a date such as 2024-06-01)", config.sinceComments)
+		}
+		config.sinceCommentsAt = since
 	}
-	if includeDetails && len(item.Components.Component) > 0 {
-		fmt.Fprintf(&sb, "- **Components:** %s\n", strings.Join(item.Components.Component, ", "))
+
+	size, err := parseSize(config.inlineImageMax)
+	if err != nil {
+		return fmt.Errorf("invalid --inline-image-max: %w", err)
 	}
-	if includeDetails && len(item.Versions.Version) > 0 {
-		fmt.Fprintf(&sb, "- **Versions:** %s\n", strings.Join(item.Versions.Version, ", "))
+	config.inlineImageBytes = size
+
+	redactions, err := parseRedactions(config.redactPatterns)
+	if err != nil {
+		return err
 	}
-	sb.WriteString("\n")
+	config.redactions = redactions
 
-	// Dates
-	sb.WriteString("## Dates\n\n")
-	fmt.Fprintf(&sb, "- **Created:** %s\n", item.Created)
-	fmt.Fprintf(&sb, "- **Updated:** %s\n", item.Updated)
-	
-	// Add custom date fields if details enabled
-	if includeDetails {
-		for _, cf := range item.CustomFields.CustomField {
-			if strings.Contains(strings.ToLower(cf.CustomFieldName), "date") && len(cf.CustomFieldValues.CustomFieldValue) > 0 {
-				val := cf.CustomFieldValues.CustomFieldValue[0].Value
-				if val != "" {
-					fmt.Fprintf(&sb, "- **%s:** %s\n", cf.CustomFieldName, val)
-				}
-			}
+	if config.inputEncoding != "" {
+		if _, err := htmlindex.Get(config.inputEncoding); err != nil {
+			return fmt.Errorf("unsupported input encoding %q", config.inputEncoding)
 		}
 	}
-	sb.WriteString("\n")
 
-	// Description/Details
-	sb.WriteString("## Details\n\n")
-	sb.WriteString(decodeHTML(item.Description))
-	sb.WriteString("\n\n")
+	return nil
+}
 
-	// Comments
-	if len(item.Comments.Comment) > 0 {
-		sb.WriteString("## Comments\n\n")
-		for _, comment := range item.Comments.Comment {
-			fmt.Fprintf(&sb, "### %s\n\n", comment.Created)
-			sb.WriteString(decodeHTML(comment.Value))
-			sb.WriteString("\n\n")
+// loadConfigFiles loads the labels, icons, user map, field render map,
+// presets, template and baseline files the options refer to.
+func loadConfigFiles(config *Config) error {
+	if config.labelsFile != "" {
+		labels, err := loadLabels(config.labelsFile)
+		if err != nil {
+			return err
 		}
+		config.labels = labels
 	}
 
-	// Custom Fields (if details enabled)
-	if includeDetails && len(item.CustomFields.CustomField) > 0 {
-		sb.WriteString("## Custom Fields\n\n")
-		for _, cf := range item.CustomFields.CustomField {
-			// Skip date fields (already included above)
-			if strings.Contains(strings.ToLower(cf.CustomFieldName), "date") {
-				continue
-			}
-			
-			// Skip empty fields
-			if len(cf.CustomFieldValues.CustomFieldValue) == 0 {
-				continue
-			}
+	if config.icons {
+		status, priority, err := loadIcons(config.iconsFile)
+		if err != nil {
+			return err
+		}
+		config.statusIcons, config.priorityIcons = status, priority
+	}
 
-			hasContent := false
-			for _, val := range cf.CustomFieldValues.CustomFieldValue {
-				if val.Value != "" {
-					hasContent = true
-					break
-				}
-			}
-			
-			if !hasContent {
-				continue
-			}
+	if config.userMap != "" {
+		users, err := loadUserMap(config.userMap)
+		if err != nil {
+			return err
+		}
+		config.users = users
+	}
 
-			// Multi-value fields
-			if len(cf.CustomFieldValues.CustomFieldValue) > 1 {
-				fmt.Fprintf(&sb, "- **%s:** ", cf.CustomFieldName)
-				var values []string
-				for _, val := range cf.CustomFieldValues.CustomFieldValue {
-					if val.Value != "" {
-						values = append(values, val.Value)
-					}
-				}
-				sb.WriteString(strings.Join(values, ", "))
-				sb.WriteString("\n")
-			} else {
-				// Single value fields
-				val := cf.CustomFieldValues.CustomFieldValue[0].Value
-				if val != "" {
-					fmt.Fprintf(&sb, "- **%s:** %s\n", cf.CustomFieldName, val)
-				}
-			}
+	if config.fieldRenderMap != "" {
+		renders, err := loadFieldRenderMap(config.fieldRenderMap)
+		if err != nil {
+			return err
 		}
-		
-		// Add audit description if present
-		for _, cf := range item.CustomFields.CustomField {
-			if cf.CustomFieldName == "Audit Description" && len(cf.CustomFieldValues.CustomFieldValue) > 0 {
-				val := cf.CustomFieldValues.CustomFieldValue[0].Value
-				if val != "" {
-					sb.WriteString("\n## Audit Description\n\n")
-					sb.WriteString(decodeHTML(val))
-					sb.WriteString("\n")
-				}
-			}
+		config.fieldRenders = renders
+	}
+
+	if config.preset != "" {
+		presets, err := loadPresets(config.presetsFile)
+		if err != nil {
+			return err
+		}
+		if _, ok := presets[strings.ToLower(config.preset)]; !ok && config.preset != "auto" {
+			return fmt.Errorf("unknown --preset %q", config.preset)
 		}
+		config.presets = presets
 	}
 
-	// Attachments (if details enabled)
-	if includeDetails && len(item.Attachments.Attachment) > 0 {
-		sb.WriteString("\n## Attachments\n\n")
-		for _, att := range item.Attachments.Attachment {
-			attURL := fmt.Sprintf("%s/rest/api/3/attachment/content/%s", channelLink, att.ID)
-			fmt.Fprintf(&sb, "- [%s](%s)", att.Name, attURL)
-			if att.Size != "" || att.Created != "" {
-				sb.WriteString(" (")
-				if att.Size != "" {
-					fmt.Fprintf(&sb, "Size: %s bytes", att.Size)
-				}
-				if att.Created != "" {
-					if att.Size != "" {
-						sb.WriteString(", ")
-					}
-					fmt.Fprintf(&sb, "Created: %s", att.Created)
-				}
-				sb.WriteString(")")
-			}
-			sb.WriteString("\n")
+	if config.template != "" {
+		tmpl, err := loadTemplate(config.template)
+		if err != nil {
+			return err
 		}
+		config.tmpl = tmpl
 	}
 
-	return sb.String()
+	if config.baseline != "" {
+		issues, err := loadBaseline(config.baseline, *config)
+		if err != nil {
+			return err
+		}
+		config.baselineIssues = issues
+	}
+
+	return nil
 }
 
-func decodeHTML(s string) string {
-	// Basic HTML entity decoding and tag removal
-	s = strings.ReplaceAll(s, "&lt;", "<")
-	s = strings.ReplaceAll(s, "&gt;", ">")
-	s = strings.ReplaceAll(s, "&quot;", "\"")
-	s = strings.ReplaceAll(s, "&amp;", "&")
-	s = strings.ReplaceAll(s, "&#8217;", "'")
-	
-	// Convert HTML tags to markdown
-	s = strings.ReplaceAll(s, "<p>", "")
-	s = strings.ReplaceAll(s, "</p>", "\n\n")
-	s = strings.ReplaceAll(s, "<br/>", "\n")
-	s = strings.ReplaceAll(s, "<br />", "\n")
-	s = strings.ReplaceAll(s, "<b>", "**")
-	s = strings.ReplaceAll(s, "</b>", "**")
-	s = strings.ReplaceAll(s, "<ul>", "")
-	s = strings.ReplaceAll(s, "</ul>", "")
-	s = strings.ReplaceAll(s, "<li>", "- ")
-	s = strings.ReplaceAll(s, "</li>", "\n")
-	
-	// Convert links
-	s = convertHTMLLinks(s)
-	
-	// Convert images
-	s = convertHTMLImages(s)
-	
-	// Clean up extra whitespace
-	s = strings.TrimSpace(s)
-	
-	return s
+func parseDetailsFlag(s string) bool {
+	switch strings.ToLower(s) {
+	case "on", "enabled", "1", "true", "yes":
+		return true
+	case "off", "disabled", "0", "false", "no":
+		return false
+	default:
+		return true // default to enabled
+	}
 }
 
-func convertHTMLLinks(s string) string {
-	// Simple regex-like replacement for <a href="url">text</a>
-	for {
-		start := strings.Index(s, "<a href=\"")
-		if start == -1 {
-			break
+// parseSize parses a byte count with an optional K, M or G suffix (powers of
+// 1024), optionally followed by "B" or "iB".
+func parseSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+
+	multiplier := int64(1)
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
 		}
-		
-		urlStart := start + 9
-		urlEnd := strings.Index(s[urlStart:], "\"")
+		if multiplier > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a size", size)
+	}
+	return n * multiplier, nil
+}
+
+// readInput reads an input file, refusing files over --max-file-size before
+// any of it is loaded. A path of "-" reads standard input.
+func readInput(path string, config Config) ([]byte, error) {
+	if path == "-" {
+		return readStdin(config)
+	}
+
+	if err := checkInputSize(path, config); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return data, nil
+}
+
+// readStdin reads all of standard input, failing once it passes
+// --max-file-size.
+func readStdin(config Config) ([]byte, error) {
+	var r io.Reader = os.Stdin
+	if config.maxFileBytes > 0 {
+		r = io.LimitReader(os.Stdin, config.maxFileBytes+1)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read standard input: %w", err)
+	}
+	if config.maxFileBytes > 0 && int64(len(data)) > config.maxFileBytes {
+		return nil, fmt.Errorf("standard input is larger than --max-file-size %s", config.maxFileSize)
+	}
+	return data, nil
+}
+
+// checkInputSize returns an error if path is over --max-file-size.
+func checkInputSize(path string, config Config) error {
+	if config.maxFileBytes == 0 {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	if info.Size() > config.maxFileBytes {
+		return fmt.Errorf("file is %d bytes, larger than --max-file-size %s", info.Size(), config.maxFileSize)
+	}
+	return nil
+}
+
+// writeOutput writes a generated file, or to standard output when path is
+// "-". With --output-permissions the mode is applied even if the file
+// already existed or the umask would mask it.
+func writeOutput(path string, data []byte, config Config) error {
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	mode := config.fileMode
+	if mode == 0 {
+		mode = 0644
+	}
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return err
+	}
+	if config.fileMode != 0 {
+		return os.Chmod(path, config.fileMode)
+	}
+	return nil
+}
+
+// dirMode returns the mode for directories created for output: 0755 by
+// default, or the --output-permissions mode with an execute bit added
+// wherever it grants read access.
+func dirMode(config Config) os.FileMode {
+	if config.fileMode == 0 {
+		return 0755
+	}
+	return config.fileMode | (config.fileMode&0444)>>2
+}
+
+// encodeOutput applies --output-eol and --output-bom to Markdown about to be
+// written to a file.
+func encodeOutput(md string, config Config) []byte {
+	if config.outputEOL == "crlf" {
+		md = strings.ReplaceAll(md, "\r\n", "\n")
+		md = strings.ReplaceAll(md, "\n", "\r\n")
+	}
+	if config.outputBOM {
+		md = "\uFEFF" + md
+	}
+	return []byte(md)
+}
+
+// normalizeKeys trims and uppercases every issue key in rss, including the
+// keys of linked issues.
+func normalizeKeys(rss *RSS) {
+	for i := range rss.Channel.Items {
+		normalizeItemKeys(&rss.Channel.Items[i])
+	}
+}
+
+// normalizeItemKeys trims and uppercases the keys of one item.
+func normalizeItemKeys(item *Item) {
+	normalize := func(key string) string {
+		return strings.ToUpper(strings.TrimSpace(key))
+	}
+	item.Key.Value = normalize(item.Key.Value)
+	item.Parent.Value = normalize(item.Parent.Value)
+	for j := range item.Subtasks.Subtask {
+		item.Subtasks.Subtask[j].Value = normalize(item.Subtasks.Subtask[j].Value)
+	}
+	for j := range item.IssueLinks.IssueLinkType {
+		lt := &item.IssueLinks.IssueLinkType[j]
+		for k := range lt.OutwardLinks.IssueLink {
+			lt.OutwardLinks.IssueLink[k].IssueKey.Value = normalize(lt.OutwardLinks.IssueLink[k].IssueKey.Value)
+		}
+		for k := range lt.InwardLinks.IssueLink {
+			lt.InwardLinks.IssueLink[k].IssueKey.Value = normalize(lt.InwardLinks.IssueLink[k].IssueKey.Value)
+		}
+	}
+}
+
+// keySelected reports whether key passes the --only-keys filter. An empty
+// filter selects everything.
+func keySelected(key string, onlyKeys []string) bool {
+	if len(onlyKeys) == 0 {
+		return true
+	}
+	for _, k := range onlyKeys {
+		if strings.EqualFold(strings.TrimSpace(k), strings.TrimSpace(key)) {
+			return true
+		}
+	}
+	return false
+}
+
+func processFile(inputFile string, config Config, state *runState) error {
+	if config.verbose {
+		fmt.Fprintf(os.Stderr, "Processing %s...\n", inputFile)
+	}
+	config.inputFile = inputFile
+
+	// Read and parse XML
+	data, err := readInput(inputFile, config)
+	if err != nil {
+		return err
+	}
+
+	docs, err := parseDocuments(data, config)
+	if err != nil {
+		return fmt.Errorf("failed to parse XML: %w", err)
+	}
+	if config.verbose && len(docs) > 1 {
+		fmt.Fprintf(os.Stderr, "Found %d concatenated XML documents in %s\n", len(docs), inputFile)
+	}
+
+	items, channelLinks := documentItems(docs)
+	if len(items) == 0 {
+		return fmt.Errorf("no items found in XML")
+	}
+
+	// There is no file name to derive an output name from
+	if inputFile == "-" && config.output == "" {
+		config.output = "-"
+	}
+
+	if config.output != "" && outputIsDir(config.output) && !config.dryRun {
+		if err := os.MkdirAll(config.output, dirMode(config)); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	// With -o naming a single file, all items go into it one after another.
+	// Standard output is shared by every input, so its documents are handed
+	// to main like --combine ones, to be written in input order.
+	concatenated := concatenates(len(items), config) || config.combine || config.output == "-"
+	var combined strings.Builder
+	var combinedJSON []string
+	combinedFile := ""
+
+	// Process each item
+	for i, item := range items {
+		config.channelLink = channelLinks[i]
+
+		selected := keySelected(item.Key.Value, config.onlyKeys)
+		if !selected && config.verbose {
+			fmt.Fprintf(os.Stderr, "Skipping %s (not in --only-keys)\n", item.Key.Value)
+		}
+		if selected && !priorityAtLeast(item.Priority, config) {
+			selected = false
+			if config.verbose {
+				fmt.Fprintf(os.Stderr, "Skipping %s (below --min-priority)\n", item.Key.Value)
+			}
+		}
+		if !selected && !config.emitEmptyFile {
+			continue
+		}
+
+		item.Link = publicURL(item.Link, config)
+
+		// Determine output file, unless planOutputs already has
+		outputFile, planned := state.plannedOutput(inputFile, i)
+		if !planned {
+			outputFile = outputPath(inputFile, item, i, len(items), config)
+		}
+
+		switch {
+		case combinedFile != "":
+			outputFile = combinedFile
+		case outputFile == "-":
+			// Standard output can take any number of documents
+		case config.combine:
+			// Checked once for the whole run in main
+		case config.dryRun:
+			// reportDryRun says whether the file exists instead
+			if !planned {
+				outputFile, err = claimOutput(outputFile, config.onCollision, state)
+				if err != nil {
+					return err
+				}
+			}
+		default:
+			// Guard against two items in this run mapping to the same file
+			if !planned {
+				outputFile, err = claimOutput(outputFile, config.onCollision, state)
+				if err != nil {
+					return err
+				}
+			}
+
+			// Check if file exists, asking before overwriting it when interactive
+			if !config.append {
+				if _, err := os.Stat(outputFile); err == nil && !mayOverwrite(outputFile, config) {
+					return fmt.Errorf("output file %s already exists (use -f to overwrite)", outputFile)
+				}
+			}
+		}
+
+		var md string
+		if selected {
+			if config.dumpParsed {
+				enc := json.NewEncoder(os.Stderr)
+				enc.SetIndent("", "  ")
+				enc.SetEscapeHTML(false)
+				if err := enc.Encode(item); err != nil {
+					return fmt.Errorf("failed to dump %s: %w", item.Key.Value, err)
+				}
+			}
+
+			if config.dedupeComments {
+				var removed int
+				item.Comments.Comment, removed = dedupeComments(item.Comments.Comment)
+				if config.verbose && removed > 0 {
+					fmt.Fprintf(os.Stderr, "Removed %d duplicate comment(s) from %s\n", removed, item.Key.Value)
+				}
+			}
+
+			// Write per-comment files and work out how the main document links to them
+			if config.commentsDir != "" && len(item.Comments.Comment) > 0 && !config.dryRun {
+				if err := writeCommentFiles(item, config, state); err != nil {
+					return err
+				}
+				rel, err := filepath.Rel(filepath.Dir(outputFile), config.commentsDir)
+				if err != nil {
+					rel = config.commentsDir
+				}
+				config.commentsLinkDir = filepath.ToSlash(rel)
+			}
+			config.outputFile = outputFile
+
+			// Bundle attachment files from a full export with the document
+			if config.attachmentsDir != "" && len(item.Attachments.Attachment) > 0 && !config.dryRun {
+				paths, err := copyAttachments(item, outputFile, config, state)
+				if err != nil {
+					return err
+				}
+				config.attachmentPaths = paths
+			}
+
+			// Fetch embedded images so the document doesn't depend on JIRA
+			if config.downloadImages != "" && config.format != "json" && !config.dryRun {
+				paths, err := downloadImages(item, outputFile, config, state)
+				if err != nil {
+					return err
+				}
+				config.imagePaths = paths
+			}
+
+			if config.format == "json" {
+				if md, err = itemJSON(item, config); err != nil {
+					return err
+				}
+			} else {
+				if md, err = renderItem(item, channelLinks[i], config); err != nil {
+					return err
+				}
+			}
+		} else if config.format == "json" {
+			md = fmt.Sprintf("{\"key\": %q, \"filtered_out\": true}\n", item.Key.Value)
+		} else {
+			// Keep a 1:1 item/file mapping for pipelines that expect it
+			md = fmt.Sprintf("# %s\n\n_%s_\n", item.Key.Value, config.label("filtered_out"))
+		}
+
+		// Validate the output before it is written
+		if config.lint && config.format != "json" {
+			warnings := lintMarkdown(md)
+			for _, warning := range warnings {
+				fmt.Fprintf(os.Stderr, "lint: %s: %s\n", outputFile, warning)
+			}
+			if config.strict && len(warnings) > 0 {
+				return fmt.Errorf("lint found %d problem(s) in %s", len(warnings), outputFile)
+			}
+		}
+
+		// Catch dead attachment URLs and external references
+		if config.checkLinks && selected {
+			broken := checkLinks(md)
+			for _, link := range broken {
+				fmt.Fprintf(os.Stderr, "links: %s: broken link %s\n", outputFile, link)
+			}
+			if config.strict && len(broken) > 0 {
+				return fmt.Errorf("found %d broken link(s) in %s", len(broken), outputFile)
+			}
+		}
+
+		if selected {
+			state.addIndexEntry(indexEntry{item, outputFile, inputPosition(inputFile, config), i})
+		}
+
+		if concatenated && config.format == "json" {
+			combinedJSON = append(combinedJSON, md)
+			combinedFile = outputFile
+			continue
+		} else if concatenated {
+			if combined.Len() > 0 {
+				combined.WriteString("\n---\n\n")
+			}
+			combined.WriteString(md)
+			combinedFile = outputFile
+			continue
+		}
+
+		if config.dryRun {
+			reportDryRun(inputFile, outputFile, len(encodeOutput(md, config)), config)
+			continue
+		}
+
+		// Write output
+		if err := writeDocument(outputFile, md, config); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		recordOutput(outputFile, config, state)
+	}
+
+	if combinedFile != "" && (config.combine || combinedFile == "-") {
+		if config.format == "json" {
+			state.addCombined(inputFile, combinedJSON)
+		} else {
+			state.addCombined(inputFile, []string{combined.String()})
+		}
+	} else if combinedFile != "" {
+		out := combined.String()
+		if config.format == "json" {
+			out = jsonArray(combinedJSON)
+		}
+		if config.dryRun {
+			reportDryRun(inputFile, combinedFile, len(encodeOutput(out, config)), config)
+			return nil
+		}
+		if err := writeDocument(combinedFile, out, config); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		recordOutput(combinedFile, config, state)
+	}
+
+	return nil
+}
+
+// recordOutput notes a written document for the manifest and reports it.
+// Documents written to standard output are neither.
+func recordOutput(path string, config Config, state *runState) {
+	if path == "-" {
+		return
+	}
+	state.addOutput(config.inputFile, path)
+	if config.verbose {
+		fmt.Fprintf(os.Stderr, "Created %s\n", path)
+	}
+}
+
+// outputExtension returns the extension of default output file names.
+func outputExtension(config Config) string {
+	switch {
+	case config.format == "json":
+		return ".json"
+	case config.details:
+		return ".details.md"
+	default:
+		return ".md"
+	}
+}
+
+// concatenates reports whether count items from one input are written to a
+// single file: when -o names a file rather than a directory.
+func concatenates(count int, config Config) bool {
+	return count > 1 && config.output != "" && !outputIsDir(config.output)
+}
+
+// outputIsDir reports whether -o names a directory, either one that exists
+// or a path ending in a separator.
+func outputIsDir(output string) bool {
+	if output == "-" {
+		return false
+	}
+	if strings.HasSuffix(output, "/") || strings.HasSuffix(output, string(filepath.Separator)) {
+		return true
+	}
+	info, err := os.Stat(output)
+	return err == nil && info.IsDir()
+}
+
+// outputPath returns the output file for the index-th of count items
+// converted from inputFile: a per-key file in an -o directory, the -o file
+// itself, or a name derived from inputFile.
+func outputPath(inputFile string, item Item, index, count int, config Config) string {
+	outputFile := config.output
+	if outputFile != "" && outputIsDir(outputFile) {
+		// One file per item, named after its key
+		extension := outputExtension(config)
+		outputFile = filepath.Join(outputFile, fileNamePart(item.Key.Value)+extension)
+	} else if outputFile == "" {
+		base := strings.TrimSuffix(inputFile, filepath.Ext(inputFile))
+		extension := outputExtension(config)
+		
+		// If multiple items, insert issue key in filename
+		if count > 1 {
+			outputFile = fmt.Sprintf("%s-%s%s", base, fileNamePart(item.Key.Value), extension)
+		} else {
+			outputFile = base + extension
+		}
+	}
+
+	return outputFile
+}
+
+// commentText makes s safe to place inside an HTML comment, which "--"
+// can't appear in and "-->" would end, by spacing out runs of hyphens.
+func commentText(s string) string {
+	for strings.Contains(s, "--") {
+		s = strings.ReplaceAll(s, "--", "- -")
+	}
+	return s
+}
+
+// fileNamePart makes a value from the export, such as an issue key or a
+// comment id, safe to use in a file name. Only its last path element is kept,
+// so a key like "../../x" can't place files outside the output directory.
+func fileNamePart(s string) string {
+	s = filepath.Base(strings.ReplaceAll(s, "\\", "/"))
+	if s == "." || s == ".." || s == "/" {
+		return "_"
+	}
+	return s
+}
+
+// renderItem returns the document for a single item: just the changes when
+// a baseline exists for its key, otherwise the full Markdown, laid out by
+// --template when one is given.
+func renderItem(item Item, channelLink string, config Config) (string, error) {
+	var md string
+	if base, ok := config.baselineIssues[item.Key.Value]; ok {
+		md = generateChanges(item, base, config)
+	} else if config.tmpl != nil {
+		var err error
+		if md, err = executeTemplate(item, channelLink, config); err != nil {
+			return "", err
+		}
+	} else {
+		md = generateMarkdown(item, channelLink, config)
+	}
+
+	if len(config.redactions) > 0 {
+		var count int
+		md, count = redact(md, config.redactions)
+		if config.verbose && count > 0 {
+			fmt.Fprintf(os.Stderr, "Redacted %d match(es) in %s\n", count, item.Key.Value)
+		}
+	}
+
+	if config.inlineImages {
+		md = inlineImages(md, config)
+	}
+
+	// Runs last so links from every transform are collected
+	if config.referenceLinks {
+		md = referenceLinks(md)
+	}
+
+	if !config.keepTrailingWS {
+		md = trimTrailingWhitespace(md)
+	}
+
+	return md, nil
+}
+
+// field is a labelled value rendered as a "- **Label:** value" bullet.
+type field struct {
+	label string
+	value string
+}
+
+// section is one top-level part of the generated document. Its fields are
+// rendered first, followed by the free-form Markdown body.
+type section struct {
+	id      string
+	heading string
+	fields  []field
+	body    string
+}
+
+func generateMarkdown(item Item, channelLink string, config Config) string {
+	var sb strings.Builder
+
+	// Metadata for static site generators, and to keep the Overview fields
+	// when that section is left out
+	if config.frontMatter || config.noOverview {
+		sb.WriteString(frontMatter(item, config))
+	}
+
+	// Watermark banner for drafts and internal documents
+	if config.watermark != "" {
+		fmt.Fprintf(&sb, "<!-- watermark: %s -->\n", commentText(config.watermark))
+		fmt.Fprintf(&sb, "> ⚠️ **%s**\n\n", config.watermark)
+	}
+
+	// Title
+	title := fmt.Sprintf("%s: %s", item.Key.Value, itemSummary(item, config))
+	fmt.Fprintf(&sb, "# %s\n", title)
+	// Some exports omit <link>; an empty one would render as "[]()"
+	if item.Link != "" && !config.noLinkLine {
+		if !config.compact {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "**%s:** [%s](%s)\n", config.label("link"), item.Link, item.Link)
+	}
+	sb.WriteString("\n")
+
+	if config.badges {
+		if badges := badgeLine(item, config); badges != "" {
+			sb.WriteString(badges)
+			sb.WriteString("\n\n")
+		}
+	}
+
+	var body strings.Builder
+	for i, sec := range buildSections(item, channelLink, config) {
+		if i > 0 {
+			body.WriteString("\n")
+		}
+		body.WriteString(renderSection(sec, config))
+	}
+
+	// Built from the rendered sections, so it lists only those that exist.
+	// Compact mode has no headings to link to.
+	if config.toc && !config.compact {
+		sb.WriteString(tableOfContents(title, body.String(), config))
+	}
+	sb.WriteString(body.String())
+
+	return sb.String()
+}
+
+// buildSections collects the sections of the document for item, in order,
+// omitting those with nothing to show.
+func buildSections(item Item, channelLink string, config Config) []section {
+	var sections []section
+	includeDetails := config.details
+	preset := itemPreset(item, config)
+
+	// Overview
+	overview := section{id: "overview", heading: config.label("overview")}
+	overview.fields = append(overview.fields,
+		field{config.label("type"), formatType(item.Type, config)},
+		field{config.label("priority"), formatPriority(item.Priority, config)},
+		field{config.label("status"), formatStatus(item, config)},
+		field{config.label("resolution"), formatResolution(item, config)},
+		field{config.label("assignee"), formatUser(item.Assignee, config)},
+		field{config.label("reporter"), formatUser(item.Reporter, config)},
+	)
+	if len(item.Labels.Label) > 0 {
+		overview.fields = append(overview.fields, field{config.label("labels"), strings.Join(item.Labels.Label, ", ")})
+	}
+	if includeDetails && len(item.Components.Component) > 0 {
+		overview.fields = append(overview.fields, field{config.label("components"), strings.Join(item.Components.Component, ", ")})
+	}
+	if includeDetails && len(item.Versions.Version) > 0 {
+		overview.fields = append(overview.fields, field{config.label("versions"), strings.Join(item.Versions.Version, ", ")})
+	}
+	if !config.noOverview {
+		sections = append(sections, overview)
+	}
+
+	// Dates
+	dates := section{id: "dates", heading: config.label("dates")}
+	dates.fields = append(dates.fields,
+		field{config.label("created"), formatDate(item.Created, config)},
+		field{config.label("updated"), formatDate(item.Updated, config)},
+	)
+
+	// Add custom date fields if details enabled
+	if includeDetails {
+		for _, cf := range item.CustomFields.CustomField {
+			if isDateField(cf, config) && len(cf.CustomFieldValues.CustomFieldValue) > 0 {
+				val := cf.CustomFieldValues.CustomFieldValue[0].Value
+				if val != "" {
+					dates.fields = append(dates.fields, field{cf.CustomFieldName, formatDate(val, config)})
+				}
+			}
+		}
+	}
+	sections = append(sections, dates)
+
+	// Timeline
+	if config.timeline {
+		if timeline := timelineSection(item, config); timeline.body != "" {
+			sections = append(sections, timeline)
+		}
+	}
+
+	// Recent activity
+	if config.activityDigest > 0 {
+		if activity := activitySection(item, config); activity.body != "" {
+			sections = append(sections, activity)
+		}
+	}
+
+	// Environment is only shown by presets, to keep the default layout stable
+	if preset != nil {
+		if environment := renderBody(item.Environment, config); environment != "" {
+			sections = append(sections, section{id: "environment", heading: config.label("environment"), body: environment + "\n"})
+		}
+	}
+
+	// Description/Details
+	description, source := renderDescription(item, config)
+	if config.verbose {
+		switch source {
+		case "description":
+		case "none":
+			fmt.Fprintf(os.Stderr, "No description found for %s\n", item.Key.Value)
+		default:
+			fmt.Fprintf(os.Stderr, "Using %s as the description of %s\n", source, item.Key.Value)
+		}
+	}
+	if config.keepOriginal {
+		description += originalHTML(item, config)
+	}
+	sections = append(sections, section{
+		id:      "details",
+		heading: config.label("details"),
+		body:    description + "\n",
+	})
+
+	// Parent, subtasks and linked issues
+	if related := relatedIssuesSection(item, config); len(related.fields) > 0 {
+		sections = append(sections, related)
+	}
+
+	// Dependency diagram
+	if config.mermaidDeps {
+		if diagram := mermaidDeps(item); diagram != "" {
+			sections = append(sections, section{id: "dependencies", heading: config.label("dependencies"), body: diagram})
+		}
+	}
+
+	// Links to pages outside JIRA
+	if related := remoteLinksSection(item, config); related.body != "" {
+		sections = append(sections, related)
+	}
+
+	// Comments
+	if len(item.Comments.Comment) > 0 {
+		var body strings.Builder
+		if hidden := hiddenComments(item.Comments.Comment, config); hidden > 0 {
+			fmt.Fprintf(&body, "_%d %s_\n", hidden, config.label("older_comments_hidden"))
+		}
+		for i, comment := range item.Comments.Comment {
+			if !commentShown(comment, config) {
+				continue
+			}
+			if body.Len() > 0 {
+				body.WriteString("\n")
+			}
+			anchored := config.commentAnchors && comment.ID != ""
+			if anchored {
+				// Stable target for links like KEY.md#comment-10234
+				fmt.Fprintf(&body, "<a id=\"comment-%s\"></a>", comment.ID)
+				if !config.compact {
+					body.WriteString("\n\n")
+				}
+			}
+			if config.compact {
+				fmt.Fprintf(&body, "_%s:_ ", commentHeading(comment, config))
+			} else {
+				fmt.Fprintf(&body, "### %s\n\n", commentHeading(comment, config))
+			}
+			if config.commentsLinkDir != "" {
+				fmt.Fprintf(&body, "[%s](%s/%s)\n\n", config.label("comment_file"), config.commentsLinkDir, commentFileName(item.Key.Value, comment, i))
+			}
+			if anchored && item.Link != "" {
+				fmt.Fprintf(&body, "[%s](%s?focusedCommentId=%s#comment-%s)\n\n", config.label("permalink"), item.Link, comment.ID, comment.ID)
+			}
+			body.WriteString(renderBody(comment.Value, config))
+			body.WriteString("\n")
+		}
+		sections = append(sections, section{id: "comments", heading: config.label("comments"), body: body.String()})
+	}
+
+	// Time tracking summary, followed by the work log (if details enabled)
+	if includeDetails {
+		if timeTracking := timeTrackingSection(item, config); len(timeTracking.fields) > 0 {
+			sections = append(sections, timeTracking)
+		}
+	}
+	if includeDetails && len(item.Worklogs.Worklog) > 0 {
+		var body strings.Builder
+		fmt.Fprintf(&body, "| %s | %s | %s | %s |\n", config.label("author"), config.label("started"), config.label("time_spent"), config.label("comment"))
+		body.WriteString("|---|---|---|---|\n")
+		for _, wl := range item.Worklogs.Worklog {
+			started := wl.TimeStarted
+			if started == "" {
+				started = wl.Created
+			}
+			fmt.Fprintf(&body, "| %s | %s | %s | %s |\n", tableCell(userName(wl.Author, config)), tableCell(formatDate(started, config)), tableCell(wl.TimeSpent), tableCell(renderBody(wl.Comment, config)))
+		}
+		sections = append(sections, section{id: "work_log", heading: config.label("work_log"), body: body.String()})
+	}
+
+	// Custom Fields (if details enabled)
+	if includeDetails && len(item.CustomFields.CustomField) > 0 {
+		custom := section{id: "custom_fields", heading: config.label("custom_fields")}
+		for _, cf := range item.CustomFields.CustomField {
+			// Skip date fields (already included above)
+			if isDateField(cf, config) {
+				continue
+			}
+
+			if !fieldSelected(cf, config) {
+				continue
+			}
+
+			// Skip empty fields
+			value := renderCustomField(cf, config)
+			if value == "" {
+				continue
+			}
+
+			custom.fields = append(custom.fields, field{cf.CustomFieldName, value})
+		}
+		if len(custom.fields) > 0 && config.appendFields {
+			// Each field becomes a term followed by its value as a paragraph
+			for i := range sections {
+				if sections[i].id != "details" {
+					continue
+				}
+				var body strings.Builder
+				body.WriteString(sections[i].body)
+				for _, f := range custom.fields {
+					fmt.Fprintf(&body, "\n**%s**\n\n%s\n", f.label, f.value)
+				}
+				sections[i].body = body.String()
+			}
+		} else if len(custom.fields) > 0 {
+			sections = append(sections, custom)
+		}
+
+		// Add audit description if present
+		for _, cf := range item.CustomFields.CustomField {
+			if cf.CustomFieldName == "Audit Description" && len(cf.CustomFieldValues.CustomFieldValue) > 0 {
+				val := cf.CustomFieldValues.CustomFieldValue[0].Value
+				if val != "" {
+					sections = append(sections, section{
+						id:      "audit_description",
+						heading: config.label("audit_description"),
+						body:    renderBody(val, config) + "\n",
+					})
+				}
+			}
+		}
+	}
+
+	// Attachments (if details enabled)
+	if includeDetails && len(item.Attachments.Attachment) > 0 {
+		var body strings.Builder
+		for _, att := range item.Attachments.Attachment {
+			attURL := fmt.Sprintf("%s/rest/api/3/attachment/content/%s", channelLink, att.ID)
+			if path, ok := config.attachmentPaths[att.ID]; ok {
+				attURL = path
+			}
+			name := att.Name
+			if name == "" {
+				name = config.imagePlaceholder
+			}
+			fmt.Fprintf(&body, "- [%s](%s)", name, attURL)
+			if att.Size != "" || att.Created != "" {
+				body.WriteString(" (")
+				if att.Size != "" {
+					fmt.Fprintf(&body, "%s: %s %s", config.label("size"), att.Size, config.label("bytes"))
+				}
+				if att.Created != "" {
+					if att.Size != "" {
+						body.WriteString(", ")
+					}
+					fmt.Fprintf(&body, "%s: %s", config.label("created"), formatDate(att.Created, config))
+				}
+				body.WriteString(")")
+			}
+			body.WriteString("\n")
+		}
+		sections = append(sections, section{id: "attachments", heading: config.label("attachments"), body: body.String()})
+	}
+
+	if preset != nil {
+		sections = applyPreset(sections, preset)
+	}
+
+	return sections
+}
+
+// frontMatter returns the title, key, Overview fields and dates of item as a
+// YAML front matter block, for --front-matter and --no-overview. Empty
+// fields are left out.
+func frontMatter(item Item, config Config) string {
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	fmt.Fprintf(&sb, "title: %s\n", yamlString(itemSummary(item, config)))
+	fmt.Fprintf(&sb, "key: %s\n", yamlString(item.Key.Value))
+	for _, f := range []struct{ name, value string }{
+		{"type", item.Type.Value},
+		{"status", item.Status.Value},
+		{"priority", item.Priority.Value},
+		{"resolution", item.Resolution.Value},
+		{"assignee", item.Assignee.Value},
+		{"reporter", item.Reporter.Value},
+		{"created", formatDate(item.Created, config)},
+		{"updated", formatDate(item.Updated, config)},
+	} {
+		if strings.TrimSpace(f.value) != "" {
+			fmt.Fprintf(&sb, "%s: %s\n", f.name, yamlString(strings.TrimSpace(f.value)))
+		}
+	}
+	if len(item.Labels.Label) > 0 {
+		sb.WriteString("labels:\n")
+		for _, label := range item.Labels.Label {
+			fmt.Fprintf(&sb, "  - %s\n", yamlString(label))
+		}
+	}
+	sb.WriteString("---\n\n")
+	return sb.String()
+}
+
+// renderSection writes a section as a "## Heading" followed by its fields as
+// bullets and then its body. In compact mode the heading becomes a bold
+// inline label and the fields are joined onto a single line.
+func renderSection(sec section, config Config) string {
+	var sb strings.Builder
+
+	if config.compact {
+		fmt.Fprintf(&sb, "**%s:**", sec.heading)
+		if len(sec.fields) > 0 {
+			parts := make([]string, len(sec.fields))
+			for i, f := range sec.fields {
+				parts[i] = fmt.Sprintf("**%s:** %s", f.label, f.value)
+			}
+			sb.WriteString(" ")
+			sb.WriteString(strings.Join(parts, " · "))
+		}
+		sb.WriteString("\n")
+		if sec.body != "" {
+			// Tables can't start mid-paragraph
+			if strings.HasPrefix(sec.body, "|") {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(sec.body)
+		}
+		return sb.String()
+	}
+
+	fmt.Fprintf(&sb, "## %s\n\n", sec.heading)
+	for _, f := range sec.fields {
+		if strings.Contains(f.value, "\n") {
+			// Multi-line values such as code blocks are indented into the item
+			fmt.Fprintf(&sb, "- **%s:**\n  %s\n", f.label, strings.ReplaceAll(f.value, "\n", "\n  "))
+			continue
+		}
+		fmt.Fprintf(&sb, "- **%s:** %s\n", f.label, f.value)
+	}
+	if len(sec.fields) > 0 && sec.body != "" {
+		sb.WriteString("\n")
+	}
+	sb.WriteString(sec.body)
+
+	return sb.String()
+}
+
+// isResolved reports whether the issue carries a real resolution. Open issues
+// either omit the element or export JIRA's "Unresolved" placeholder (id -1).
+func isResolved(item Item) bool {
+	value := strings.TrimSpace(item.Resolution.Value)
+	return value != "" && item.Resolution.ID != "-1" && !strings.EqualFold(value, "Unresolved")
+}
+
+// joinValues combines the values of a multi-value field according to
+// --multivalue-style: on one line separated by commas, as a nested list, or
+// one per line.
+func joinValues(values []string, config Config) string {
+	if len(values) < 2 {
+		return strings.Join(values, "")
+	}
+	switch config.multivalueStyle {
+	case "bullets":
+		return "- " + strings.Join(values, "\n- ")
+	case "newline":
+		return strings.Join(values, "\\\n")
+	default:
+		return strings.Join(values, ", ")
+	}
+}
+
+// commentShown reports whether a comment passes --since-comments. Comments
+// whose date can't be parsed are always shown.
+func commentShown(comment Comment, config Config) bool {
+	if config.sinceCommentsAt.IsZero() {
+		return true
+	}
+	created, ok := parseJiraDate(comment.Created)
+	return !ok || !created.Before(config.sinceCommentsAt)
+}
+
+// commentHeading returns "author — date" for a comment, or just the date
+// when the export has no author.
+func commentHeading(comment Comment, config Config) string {
+	created := formatDate(comment.Created, config)
+	if author := userName(comment.Author, config); author != "" {
+		return author + " — " + created
+	}
+	return created
+}
+
+// hiddenComments counts the comments left out by --since-comments.
+func hiddenComments(comments []Comment, config Config) int {
+	hidden := 0
+	for _, comment := range comments {
+		if !commentShown(comment, config) {
+			hidden++
+		}
+	}
+	return hidden
+}
+
+// itemSummary returns the issue summary with any HTML entities the export
+// left encoded (such as "Fix &amp; improve") decoded. With --summary-field
+// the named custom field is used instead, unless it is empty.
+func itemSummary(item Item, config Config) string {
+	if config.summaryField != "" {
+		for _, cf := range item.CustomFields.CustomField {
+			if !strings.EqualFold(strings.TrimSpace(cf.CustomFieldName), config.summaryField) && cf.ID != config.summaryField {
+				continue
+			}
+			for _, v := range cf.CustomFieldValues.CustomFieldValue {
+				if summary := html.UnescapeString(strings.TrimSpace(v.Value)); summary != "" {
+					return summary
+				}
+			}
+		}
+	}
+	return html.UnescapeString(strings.TrimSpace(item.Summary))
+}
+
+// formatType renders the issue type, preceded by its icon with --type-badge
+// when the export provides one.
+func formatType(t TypeField, config Config) string {
+	if config.typeBadge && t.IconURL != "" && t.Value != "" {
+		return markdownImage(t.Value, t.IconURL) + " " + t.Value
+	}
+	return t.Value
+}
+
+// formatUser renders a person's name, preceded by their avatar with
+// --avatars when the export provides one.
+func formatUser(user User, config Config) string {
+	if config.avatars && user.AvatarURL != "" && user.Value != "" {
+		return markdownImage("", user.AvatarURL) + " " + user.Value
+	}
+	return user.Value
+}
+
+// categoryNames maps the status category keys used by JIRA to their
+// display names.
+var categoryNames = map[string]string{
+	"new":           "To Do",
+	"indeterminate": "In Progress",
+	"done":          "Done",
+}
+
+// categoryEmoji is the --status-emoji prefix for each status category.
+var categoryEmoji = map[string]string{
+	"To Do":       "⚪",
+	"In Progress": "🔵",
+	"Done":        "✅",
+}
+
+// statusCategory returns the coarse category (To Do, In Progress, Done) of
+// the issue's status, or "" if the export doesn't say. Exports carry it
+// either as an attribute of <status> or as a separate <statusCategory>.
+func statusCategory(item Item) string {
+	if category := strings.TrimSpace(item.Status.Category); category != "" {
+		return category
+	}
+	if name := strings.TrimSpace(item.StatusCategory.Value); name != "" {
+		return name
+	}
+	return categoryNames[strings.ToLower(item.StatusCategory.Key)]
+}
+
+// formatStatus renders the status, followed by its category with
+// --status-category and prefixed with the category's emoji with
+// --status-emoji.
+func formatStatus(item Item, config Config) string {
+	value := item.Status.Value
+	category := statusCategory(item)
+
+	if category != "" && config.statusCategory && !strings.EqualFold(category, strings.TrimSpace(value)) {
+		value = fmt.Sprintf("%s (%s)", value, category)
+	}
+	if emoji, ok := categoryEmoji[category]; ok && config.statusEmoji {
+		return emoji + " " + value
+	}
+	if config.icons {
+		if icon, ok := config.statusIcons[strings.ToLower(strings.TrimSpace(item.Status.Value))]; ok {
+			return icon + " " + value
+		}
+	}
+	return value
+}
+
+// formatPriority renders the priority, preceded by its emoji with --icons.
+func formatPriority(p Priority, config Config) string {
+	if config.icons {
+		return withIcon(p.Value, config.priorityIcons)
+	}
+	return p.Value
+}
+
+func formatResolution(item Item, config Config) string {
+	resolved := isResolved(item)
+
+	value := strings.TrimSpace(item.Resolution.Value)
+	if !resolved {
+		value = config.label("unresolved")
+	}
+
+	if config.statusEmoji {
+		if resolved {
+			return "✅ " + value
+		}
+		return "❌ " + value
+	}
+	return value
+}
+
+// renderBody converts a rich-text field (description, comment, work log
+// comment) to Markdown. Every body goes through here so that all of them get
+// the same transforms.
+func renderBody(s string, config Config) string {
+	s = decodeHTML(s, config)
+	s = absoluteURLs(s, config.channelLink)
+	if config.publicBodyLinks {
+		s = publicBodyLinks(s, config)
+	}
+	return s
+}
+
+// minFallbackLength is how long a text custom field must be to stand in for
+// a missing description.
+const minFallbackLength = 40
+
+// renderDescription converts the issue description and reports where it came
+// from. With --prefer-rendered-body, JIRA's pre-rendered HTML is used instead
+// when the export has it, either as <renderedBody> or as a "Rendered
+// Description" custom field; that variant is always HTML, so wiki conversion
+// is skipped. An empty description falls back to the
+// --description-fallback-field custom field, or else the first substantial
+// text custom field, and finally to a "no description" note.
+func renderDescription(item Item, config Config) (string, string) {
+	if config.preferRendered {
+		if rendered := renderedDescription(item); rendered != "" {
+			config.inputFormat = "html"
+			return renderBody(rendered, config), "rendered body"
+		}
+	}
+
+	if description := renderBody(item.Description, config); description != "" {
+		return description, "description"
+	}
+
+	for _, cf := range item.CustomFields.CustomField {
+		if isDateField(cf, config) || len(cf.CustomFieldValues.CustomFieldValue) == 0 {
+			continue
+		}
+		value := cf.CustomFieldValues.CustomFieldValue[0]
+		if config.fallbackField != "" {
+			if !strings.EqualFold(strings.TrimSpace(cf.CustomFieldName), config.fallbackField) {
+				continue
+			}
+		} else if _, nested := structuredNames(strings.TrimSpace(value.Inner)); nested || len(strings.TrimSpace(value.Value)) < minFallbackLength {
+			continue
+		}
+		if body := renderBody(value.Value, config); body != "" {
+			return body, "custom field " + cf.CustomFieldName
+		}
+	}
+
+	return "_" + config.label("no_description") + "_", "none"
+}
+
+// originalHTML returns the description's HTML as exported, in a collapsed
+// <details> block for --keep-original, or "" if there is no description.
+func originalHTML(item Item, config Config) string {
+	raw := strings.TrimSpace(normalizeNewlines(item.Description))
+	if raw == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n\n<details>\n<summary>%s</summary>\n\n%s\n\n</details>", config.label("original_html"), renderFence(raw, "html"))
+}
+
+// renderedDescription returns the pre-rendered HTML description of item, or
+// "" if the export doesn't include one.
+func renderedDescription(item Item) string {
+	if strings.TrimSpace(item.RenderedBody) != "" {
+		return item.RenderedBody
+	}
+	for _, cf := range item.CustomFields.CustomField {
+		name := strings.ToLower(strings.TrimSpace(cf.CustomFieldName))
+		if (name == "rendered description" || name == "rendered body") && len(cf.CustomFieldValues.CustomFieldValue) > 0 {
+			if value := cf.CustomFieldValues.CustomFieldValue[0].Value; strings.TrimSpace(value) != "" {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+func decodeHTML(s string, config Config) string {
+	// Character references such as &#13; survive XML decoding as raw CRs
+	s = normalizeNewlines(s)
+
+	if config.inputFormat == "auto" {
+		config.inputFormat = detectMarkup(s)
+	}
+
+	// Preformatted blocks are converted verbatim and set aside
+	s, preBlocks := extractPreBlocks(s, config.codeLineNumbers)
+	s, preBlocks = extractCodeMacros(s, preBlocks, config.codeLineNumbers, config.inputFormat != "wiki")
+	s, preBlocks = extractInlineCode(s, preBlocks)
+
+	// So are tags the user wants to keep as HTML
+	s, keptTags := extractKeptTags(s, config.keepTags)
+
+	// Panels are marked now and quoted once their contents are converted
+	s = markPanels(s)
+
+	// Tables first, while their cells are still delimited
+	s = convertHTMLTables(s)
+
+	// Convert HTML tags to markdown
+	s = strings.ReplaceAll(s, "<p>", "")
+	s = strings.ReplaceAll(s, "</p>", "\n\n")
+	s = strings.ReplaceAll(s, "<br/>", "\n")
+	s = strings.ReplaceAll(s, "<br />", "\n")
+	s = convertHTMLHeadings(s, config)
+	s = convertHTMLInline(s)
+	s = convertHTMLLists(s)
+	s = convertMentions(s, config)
+	
+	// Convert links
+	s = convertHTMLLinks(s)
+	
+	// Convert images
+	s = convertHTMLImages(s, config)
+
+	// Decode entities only now that the tags are gone, so an escaped
+	// "&lt;b&gt;" stays text and "&amp;lt;" decodes once, to "&lt;"
+	s = html.UnescapeString(s)
+
+	if config.inputFormat == "wiki" {
+		s = convertWiki(s, config)
+	}
+
+	// Whatever macros are left have no transform of their own
+	if config.stripMacros {
+		var names []string
+		s, names = stripMacros(s)
+		if config.verbose && len(names) > 0 {
+			fmt.Fprintf(os.Stderr, "Stripped unknown macros: %s\n", strings.Join(names, ", "))
+		}
+	}
+
+	// Collapse runs of blank lines left behind by removed tags
+	for strings.Contains(s, "\n\n\n") {
+		s = strings.ReplaceAll(s, "\n\n\n", "\n\n")
+	}
+
+	s = restorePreBlocks(s, preBlocks)
+	s = restoreKeptTags(s, keptTags)
+	s = renderPanels(s, config)
+
+	// Clean up extra whitespace
+	s = strings.TrimSpace(s)
+	
+	return s
+}
+
+func convertHTMLLinks(s string) string {
+	// Single left-to-right pass replacing <a href="url">text</a> with
+	// [text](url); the remainder is only ever resliced, never rebuilt
+	var sb strings.Builder
+	sb.Grow(len(s))
+
+	for {
+		start := strings.Index(s, "<a href=\"")
+		if start == -1 {
+			break
+		}
+
+		urlStart := start + 9
+		urlEnd := strings.Index(s[urlStart:], "\"")
 		if urlEnd == -1 {
 			break
 		}
 		urlEnd += urlStart
-		
+
 		url := s[urlStart:urlEnd]
-		
+
 		textStart := strings.Index(s[urlEnd:], ">")
 		if textStart == -1 {
 			break
 		}
 		textStart += urlEnd + 1
-		
+
 		textEnd := strings.Index(s[textStart:], "</a>")
 		if textEnd == -1 {
 			break
 		}
 		textEnd += textStart
-		
+
 		text := s[textStart:textEnd]
-		
-		// Replace with markdown link
-		markdown := fmt.Sprintf("[%s](%s)", text, url)
-		s = s[:start] + markdown + s[textEnd+4:]
+
+		// Emit everything before the anchor, then the markdown link
+		sb.WriteString(s[:start])
+		fmt.Fprintf(&sb, "[%s](%s)", text, url)
+		s = s[textEnd+4:]
 	}
-	
-	return s
+	sb.WriteString(s)
+
+	return sb.String()
 }
 
-func convertHTMLImages(s string) string {
-	// Convert <img src="url" ... /> to ![Image](url)
+func convertHTMLImages(s string, config Config) string {
+	// Single left-to-right pass replacing <img src="url" ... /> (optionally
+	// wrapped in <span class="image-wrap">) with ![alt](url "title"), pointing
+	// at the local copy when --download-images saved one
+	var sb strings.Builder
+	sb.Grow(len(s))
+
 	for {
 		start := strings.Index(s, "<img src=\"")
 		if start == -1 {
-			// Also check for <span class="image-wrap">
-			start = strings.Index(s, "<span class=\"image-wrap\"")
-			if start == -1 {
-				break
-			}
-			// Find the img tag inside
-			imgStart := strings.Index(s[start:], "<img src=\"")
-			if imgStart == -1 {
-				break
+			break
+		}
+		imgStart := start
+
+		// Swallow an enclosing <span class="image-wrap"> opening tag
+		if wrap := strings.LastIndex(s[:start], "<span class=\"image-wrap\""); wrap != -1 {
+			if wrapEnd := strings.Index(s[wrap:], ">"); wrapEnd != -1 && strings.TrimSpace(s[wrap+wrapEnd+1:start]) == "" {
+				start = wrap
 			}
-			start = start + imgStart
 		}
-		
-		urlStart := start + 10
+
+		urlStart := imgStart + 10
 		urlEnd := strings.Index(s[urlStart:], "\"")
 		if urlEnd == -1 {
 			break
 		}
 		urlEnd += urlStart
-		
+
 		url := s[urlStart:urlEnd]
-		
-		// Find end of img tag
-		tagEnd := strings.Index(s[urlEnd:], "/>")
-		if tagEnd == -1 {
-			tagEnd = strings.Index(s[urlEnd:], ">")
+		if local, ok := config.imagePaths[url]; ok {
+			url = local
 		}
+
+		// Find end of img tag (covers both "/>" and ">")
+		tagEnd := strings.Index(s[urlEnd:], ">")
 		if tagEnd == -1 {
 			break
 		}
-		tagEnd += urlEnd + 2
-		
+		tagEnd += urlEnd + 1
+
+		alt := strings.TrimSpace(attrValue(s[imgStart:tagEnd], "alt"))
+		if alt == "" {
+			alt = config.imagePlaceholder
+		}
+		// Quotes are still &quot; here; escape them before they're decoded
+		title := strings.ReplaceAll(strings.TrimSpace(attrValue(s[imgStart:tagEnd], "title")), "&quot;", `\&quot;`)
+
 		// Check if there's a closing </span>
-		endTag := s[tagEnd:]
-		if strings.HasPrefix(strings.TrimSpace(endTag), "</span>") {
+		if strings.HasPrefix(strings.TrimSpace(s[tagEnd:]), "</span>") {
 			tagEnd += strings.Index(s[tagEnd:], "</span>") + 7
 		}
-		
-		// Replace with markdown image
-		markdown := fmt.Sprintf("![Image](%s)", url)
-		s = s[:start] + markdown + s[tagEnd:]
+
+		// Emit everything before the image, then the markdown image
+		sb.WriteString(s[:start])
+		if title != "" {
+			fmt.Fprintf(&sb, "![%s](%s \"%s\")", alt, url, title)
+		} else {
+			sb.WriteString(markdownImage(alt, url))
+		}
+		s = s[tagEnd:]
 	}
-	
-	return s
+	sb.WriteString(s)
+
+	return sb.String()
+}
+
+// markdownImage renders an inline Markdown image.
+func markdownImage(alt, url string) string {
+	return fmt.Sprintf("![%s](%s)", alt, url)
 }
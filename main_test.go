@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pflag "github.com/spf13/pflag"
+)
+
+// testConfig returns the options of a run with the given command-line
+// arguments, resolved as main does.
+func testConfig(t testing.TB, args ...string) Config {
+	t.Helper()
+
+	var config Config
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	defineFlags(fs, &config)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := prepareConfig(&config); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+// testItems parses an export whose channel holds the given item elements.
+func testItems(t testing.TB, config Config, items string) []Item {
+	t.Helper()
+
+	rss, err := parseRSS([]byte(`<rss version="0.92"><channel>`+items+`</channel></rss>`), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rss.Channel.Items
+}
+
+func BenchmarkConvertHTMLLinks(b *testing.B) {
+	for _, n := range []int{1000, 5000, 20000} {
+		var sb strings.Builder
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&sb, "<p>See <a href=\"https://example.com/%d\">link %d</a> and <img src=\"https://example.com/%d.png\" alt=\"img\" /></p>\n", i, i, i)
+		}
+		s := sb.String()
+		config := testConfig(b)
+
+		b.Run(fmt.Sprintf("links=%d", n), func(b *testing.B) {
+			b.SetBytes(int64(len(s)))
+			for i := 0; i < b.N; i++ {
+				convertHTMLImages(convertHTMLLinks(s), config)
+			}
+		})
+	}
+}
+
+func TestCommentImages(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment string
+		want    string
+	}{
+		{
+			name:    "attachment on the JIRA host",
+			comment: `<p>Screenshot:</p><p><span class="image-wrap"><img src="/secure/attachment/10100/shot.png" alt="shot" /></span></p>`,
+			want:    "Screenshot:\n\n![shot](https://jira.example.com/secure/attachment/10100/shot.png)",
+		},
+		{
+			name:    "absolute URL",
+			comment: `<img src="https://cdn.example.org/a.png" alt="a" />`,
+			want:    "![a](https://cdn.example.org/a.png)",
+		},
+		{
+			name:    "protocol-relative URL",
+			comment: `<img src="//cdn.example.org/a.png" alt="a" />`,
+			want:    "![a](//cdn.example.org/a.png)",
+		},
+		{
+			name:    "image syntax in code",
+			comment: `<pre>![x](/not/a/link.png)</pre>`,
+			want:    "```\n![x](/not/a/link.png)\n```",
+		},
+	}
+
+	config := testConfig(t)
+	config.channelLink = "https://jira.example.com/browse/AI"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderBody(tt.comment, config); got != tt.want {
+				t.Errorf("renderBody(%q) = %q, want %q", tt.comment, got, tt.want)
+			}
+
+			item := Item{Key: Key{Value: "AI-1"}, Summary: "Images"}
+			item.Comments.Comment = []Comment{{Author: "jsmith", Created: "Wed, 12 Jun 2024 10:30:00 +0000", Value: tt.comment}}
+			if md := generateMarkdown(item, config.channelLink, config); !strings.Contains(md, tt.want) {
+				t.Errorf("generateMarkdown comment section lacks %q:\n%s", tt.want, md)
+			}
+		})
+	}
+}
+
+func TestItemSummaryEntities(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary string // as it appears in the export's XML
+		want    string
+	}{
+		{"ampersand", "Fix &amp;amp; improve", "Fix & improve"},
+		{"angle brackets", "Escape &amp;lt;pre&amp;gt; blocks", "Escape <pre> blocks"},
+		{"quotes", "&amp;quot;Save&amp;quot; doesn&amp;#39;t work", `"Save" doesn't work`},
+		{"named and numeric", "Caf&amp;eacute; &amp;mdash; menu&amp;#8230;", "Café — menu…"},
+		{"decoded by XML only", "Fix &amp; improve", "Fix & improve"},
+		{"double escaped", "Show &amp;amp;amp; literally", "Show &amp; literally"},
+		{"surrounding space", "  Padded &amp;amp; trimmed  ", "Padded & trimmed"},
+	}
+
+	config := testConfig(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := testItems(t, config, "<item><key>AI-1</key><summary>"+tt.summary+"</summary></item>")[0]
+
+			if got := itemSummary(item, config); got != tt.want {
+				t.Errorf("itemSummary = %q, want %q", got, tt.want)
+			}
+			if md, title := generateMarkdown(item, "", config), "# AI-1: "+tt.want+"\n"; !strings.HasPrefix(md, title) {
+				t.Errorf("generateMarkdown title = %q, want %q", strings.SplitN(md, "\n", 2)[0], title)
+			}
+		})
+	}
+}
+
+func TestCommentHeading(t *testing.T) {
+	const created = "Wed, 12 Jun 2024 10:30:00 +0000"
+	tests := []struct {
+		name    string
+		comment Comment
+		users   map[string]string
+		want    string
+	}{
+		{"author", Comment{Author: "jsmith", Created: created}, nil, "jsmith — " + created},
+		{"no author", Comment{Created: created}, nil, created},
+		{"blank author", Comment{Author: "  ", Created: created}, nil, created},
+		{"mapped author", Comment{Author: "jsmith", Created: created}, map[string]string{"jsmith": "Jane Smith"}, "Jane Smith — " + created},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig(t)
+			config.users = tt.users
+			if got := commentHeading(tt.comment, config); got != tt.want {
+				t.Errorf("commentHeading = %q, want %q", got, tt.want)
+			}
+
+			// A Markdown baseline recognizes the comment by its date either way
+			item := Item{Key: Key{Value: "AI-1"}, Summary: "Comments"}
+			item.Comments.Comment = []Comment{tt.comment}
+			base := parseBaselineMarkdown(generateMarkdown(item, "", config), config)
+			if !base["AI-1"].Comments[created] {
+				t.Errorf("baseline comments = %v, want %q", base["AI-1"].Comments, created)
+			}
+		})
+	}
+}
+
+func TestConvertHTMLImages(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"alt", `<img src="https://x.test/a.png" alt="Diagram" />`, "![Diagram](https://x.test/a.png)"},
+		{"title", `<img src="https://x.test/a.png" title="Login page" />`, `![Image](https://x.test/a.png "Login page")`},
+		{"alt and title", `<img src="https://x.test/a.png" alt="Diagram" title="Login page">`, `![Diagram](https://x.test/a.png "Login page")`},
+		{"neither", `<img src="https://x.test/a.png" />`, "![Image](https://x.test/a.png)"},
+		{"blank alt", `<img src="https://x.test/a.png" alt=" " />`, "![Image](https://x.test/a.png)"},
+		{"quoted title", `<img src="https://x.test/a.png" title="the &quot;new&quot; one" />`, `![Image](https://x.test/a.png "the \&quot;new\&quot; one")`},
+		{"image-wrap span", `<span class="image-wrap" style=""><img src="https://x.test/a.png" alt="a" /></span> after`, "![a](https://x.test/a.png) after"},
+	}
+
+	config := testConfig(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := convertHTMLImages(tt.in, config); got != tt.want {
+				t.Errorf("convertHTMLImages(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileNamePart(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"AI-538", "AI-538"},
+		{"../../escaped", "escaped"},
+		{`..\..\escaped`, "escaped"},
+		{"/etc/passwd", "passwd"},
+		{"AI-1/", "AI-1"},
+		{"..", "_"},
+		{".", "_"},
+		{"", "_"},
+		{"/", "_"},
+	}
+
+	for _, tt := range tests {
+		if got := fileNamePart(tt.in); got != tt.want {
+			t.Errorf("fileNamePart(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestOutputPathStaysInOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	config := testConfig(t, "-o", dir)
+	ext := outputExtension(config)
+
+	tests := []struct {
+		key    string
+		output string
+		want   string
+	}{
+		{"AI-1", dir, filepath.Join(dir, "AI-1"+ext)},
+		{"../../escaped", dir, filepath.Join(dir, "escaped"+ext)},
+		{"../../escaped", "", "in-escaped" + ext},
+	}
+
+	for _, tt := range tests {
+		config.output = tt.output
+		if got := outputPath("in.xml", Item{Key: Key{Value: tt.key}}, 0, 2, config); got != tt.want {
+			t.Errorf("outputPath for key %q = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+
+	if got, want := commentFileName("../AI-1", Comment{ID: "../../1"}, 0), "AI-1-comment-1.md"; got != want {
+		t.Errorf("commentFileName = %q, want %q", got, want)
+	}
+}
+
+func TestOutputPermissions(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0640", 0640, false},
+		{"600", 0600, false},
+		{"0", 0, true},
+		{"0000", 0, true},
+		{"0800", 0, true},
+		{"01777", 0, true},
+		{"rw-r--r--", 0, true},
+	}
+
+	for _, tt := range tests {
+		config := testConfig(t)
+		config.outputPerms = tt.value
+		err := resolveConfig(&config)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("--output-permissions %q: err = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if err == nil && config.fileMode != tt.want {
+			t.Errorf("--output-permissions %q: mode = %o, want %o", tt.value, config.fileMode, tt.want)
+		}
+	}
+}
+
+func TestWatermarkComment(t *testing.T) {
+	tests := []struct {
+		watermark string
+		want      string
+	}{
+		{"DRAFT", "<!-- watermark: DRAFT -->\n"},
+		{"DRAFT — INTERNAL", "<!-- watermark: DRAFT — INTERNAL -->\n"},
+		{"a --> b", "<!-- watermark: a - -> b -->\n"},
+		{"x---y", "<!-- watermark: x- - -y -->\n"},
+		{"ends-", "<!-- watermark: ends- -->\n"},
+	}
+
+	for _, tt := range tests {
+		config := testConfig(t, "--watermark", tt.watermark)
+		md := generateMarkdown(Item{Key: Key{Value: "AI-1"}}, "", config)
+		if !strings.HasPrefix(md, tt.want) {
+			t.Errorf("--watermark %q: document starts %q, want %q", tt.watermark, strings.SplitN(md, "\n", 2)[0]+"\n", tt.want)
+		}
+	}
+}
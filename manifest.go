@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// writeManifest writes a SHA-256 checksum line for every output file in the
+// format read by `sha256sum -c`. File sizes are recorded as comment lines,
+// which sha256sum ignores. Paths are relative to the manifest's directory so
+// the check can be run from there. Files are listed in path order, since
+// --jobs workers record them in whatever order they finish.
+func writeManifest(path string, outputs []string, config Config) error {
+	outputs = append([]string(nil), outputs...)
+	sort.Strings(outputs)
+
+	var sb strings.Builder
+	sb.WriteString("# converttomd-jira checksum manifest (verify with: sha256sum -c)\n")
+
+	base := filepath.Dir(path)
+	seen := make(map[string]bool)
+	for _, output := range outputs {
+		// With --on-collision overwrite the same file may be listed twice
+		if seen[output] {
+			continue
+		}
+		seen[output] = true
+
+		data, err := os.ReadFile(output)
+		if err != nil {
+			return fmt.Errorf("failed to read %s for manifest: %w", output, err)
+		}
+		sum := sha256.Sum256(data)
+
+		name := output
+		if abs, err := filepath.Abs(output); err == nil {
+			if absBase, err := filepath.Abs(base); err == nil {
+				if rel, err := filepath.Rel(absBase, abs); err == nil {
+					name = rel
+				}
+			}
+		}
+		name = filepath.ToSlash(name)
+
+		fmt.Fprintf(&sb, "# %s: %d bytes\n", name, len(data))
+		fmt.Fprintf(&sb, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+	}
+
+	if err := writeOutput(path, []byte(sb.String()), config); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var anchorTagPattern = regexp.MustCompile(`(?s)<a\s[^>]*>(.*?)</a>`)
+
+var wikiMentionPattern = regexp.MustCompile(`\[~([^\]\s|]+)\]`)
+
+// loadUserMap reads a --user-map file: a JSON object mapping JIRA usernames
+// to the names to show for them.
+func loadUserMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user map: %w", err)
+	}
+
+	var users map[string]string
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("failed to parse user map: %w", err)
+	}
+
+	return users, nil
+}
+
+// userName returns the --user-map name of a username, or the username itself
+// when it isn't mapped.
+func userName(username string, config Config) string {
+	username = strings.TrimSpace(username)
+	if name, ok := config.users[username]; ok && name != "" {
+		return name
+	}
+	return username
+}
+
+// convertMentions turns user mentions into "@Name": the [~username] wiki form,
+// which JIRA also leaves in HTML exports it couldn't render, and the
+// <a class="user-hover"> anchors of rendered HTML. The name comes from
+// --user-map, then the anchor text, then the username. It runs before
+// entities are decoded, so its output is escaped.
+func convertMentions(s string, config Config) string {
+	s = anchorTagPattern.ReplaceAllStringFunc(s, func(anchor string) string {
+		tag := anchor[:strings.Index(anchor, ">")+1]
+		if !containsString(strings.Fields(attrValue(tag, "class")), "user-hover") {
+			return anchor
+		}
+
+		username := html.UnescapeString(anchorUsername(tag))
+		text := strings.TrimSpace(html.UnescapeString(htmlTagPattern.ReplaceAllString(anchorTagPattern.FindStringSubmatch(anchor)[1], "")))
+		name := userName(username, config)
+		if name == username && text != "" {
+			name = strings.TrimPrefix(text, "@")
+		}
+		if name == "" {
+			return anchor
+		}
+		return html.EscapeString("@" + name)
+	})
+
+	return wikiMentionPattern.ReplaceAllStringFunc(s, func(mention string) string {
+		username := html.UnescapeString(wikiMentionPattern.FindStringSubmatch(mention)[1])
+		return html.EscapeString(wikiMention(username, config))
+	})
+}
+
+// anchorUsername returns the username a user-hover anchor tag refers to,
+// from its rel or data-username attribute or the name in its profile URL.
+func anchorUsername(tag string) string {
+	for _, attr := range []string{"rel", "data-username"} {
+		if username := attrValue(tag, attr); username != "" {
+			return username
+		}
+	}
+	if u, err := url.Parse(html.UnescapeString(attrValue(tag, "href"))); err == nil {
+		return u.Query().Get("name")
+	}
+	return ""
+}
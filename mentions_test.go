@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestConvertMentions(t *testing.T) {
+	users := map[string]string{"jsmith": "Jane Smith"}
+	tests := []struct {
+		name   string
+		format string
+		in     string
+		users  map[string]string
+		want   string
+	}{
+		{"wiki form", "wiki", "Thanks [~adoe]!", nil, "Thanks @adoe!"},
+		{"wiki form, mapped", "wiki", "Thanks [~jsmith]!", users, "Thanks @Jane Smith!"},
+		{"wiki form in HTML", "html", "<p>cc [~jsmith]</p>", users, "cc @Jane Smith"},
+		{"anchor form", "html", `<p>cc <a href="https://jira.example.com/secure/ViewProfile.jspa?name=adoe" class="user-hover" rel="adoe">Alex Doe</a></p>`, nil, "cc @Alex Doe"},
+		{"anchor form, mapped", "html", `<p>cc <a href="https://jira.example.com/secure/ViewProfile.jspa?name=jsmith" class="user-hover" rel="jsmith">jsmith</a></p>`, users, "cc @Jane Smith"},
+		{"anchor form, name from href", "html", `<a href="/secure/ViewProfile.jspa?name=jsmith&amp;x=1" class="user-hover">J</a>`, users, "@Jane Smith"},
+		{"anchor with entities", "html", `<a class="user-hover" rel="ob" href="#">O&#39;Brien &amp; co</a>`, nil, "@O'Brien & co"},
+		{"ordinary link", "html", `<a href="https://example.com/">site</a>`, nil, "[site](https://example.com/)"},
+		{"both forms", "html", `<a class="user-hover" rel="adoe" href="#">Alex Doe</a> and [~jsmith]`, users, "@Alex Doe and @Jane Smith"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig(t, "--input-format", tt.format)
+			config.users = tt.users
+			if got := decodeHTML(tt.in, config); got != tt.want {
+				t.Errorf("decodeHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
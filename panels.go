@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+var panelTitlePattern = regexp.MustCompile(`(?s)<div class="panelHeader"[^>]*>(.*?)</div>|<p class="title"[^>]*>(.*?)</p>`)
+
+var panelIconPattern = regexp.MustCompile(`(?s)<span class="aui-icon[^"]*"[^>]*>.*?</span>`)
+
+var divTagPattern = regexp.MustCompile(`</?div(?:\s[^>]*)?>`)
+
+// panelKinds maps the class JIRA gives an {info}, {tip}, {note} or
+// {warning} macro to its label key and GitHub callout type. A plain {panel}
+// has neither.
+var panelKinds = map[string][2]string{
+	"info":    {"info", "NOTE"},
+	"success": {"tip", "TIP"},
+	"tip":     {"tip", "TIP"},
+	"note":    {"note", "IMPORTANT"},
+	"warning": {"warning", "WARNING"},
+	"error":   {"error", "CAUTION"},
+}
+
+// markPanels replaces JIRA's panel and message macro markup (<div
+// class="panel"> and <div class="aui-message ...">) with marker lines around
+// the panel body, so the body goes through the other decodeHTML transforms
+// before renderPanels turns it into a blockquote. Panels may be nested.
+func markPanels(s string) string {
+	var sb strings.Builder
+
+	for {
+		start, kind := indexPanel(s)
+		if start == -1 {
+			break
+		}
+		openEnd := strings.Index(s[start:], ">")
+		if openEnd == -1 {
+			break
+		}
+		openEnd += start + 1
+
+		closeStart := matchingDivClose(s, openEnd)
+		if closeStart == -1 {
+			break
+		}
+
+		inner := s[openEnd:closeStart]
+		title := ""
+		if m := panelTitlePattern.FindStringSubmatchIndex(inner); m != nil {
+			raw := panelTitlePattern.FindStringSubmatch(inner)
+			title = raw[1] + raw[2]
+			title = strings.Join(strings.Fields(html.UnescapeString(htmlTagPattern.ReplaceAllString(title, ""))), " ")
+			inner = inner[:m[0]] + inner[m[1]:]
+		}
+		inner = panelIconPattern.ReplaceAllString(inner, "")
+		inner = divTagPattern.ReplaceAllString(markPanels(inner), "")
+
+		sb.WriteString(s[:start])
+		fmt.Fprintf(&sb, "\n\n\x00PANEL:%s:%s\x00\n\n%s\n\n\x00/PANEL\x00\n\n", kind, title, inner)
+		s = s[closeStart+len("</div>"):]
+	}
+	sb.WriteString(s)
+
+	return sb.String()
+}
+
+// indexPanel returns the index of the first panel or message <div> in s and
+// its kind: a key of panelKinds, or "panel" for a plain panel.
+func indexPanel(s string) (int, string) {
+	offset := 0
+	for {
+		i := indexTag(s[offset:], "div")
+		if i == -1 {
+			return -1, ""
+		}
+		i += offset
+		end := strings.Index(s[i:], ">")
+		if end == -1 {
+			return -1, ""
+		}
+
+		classes := strings.Fields(attrValue(s[i:i+end+1], "class"))
+		if containsString(classes, "panel") {
+			return i, "panel"
+		}
+		if containsString(classes, "aui-message") || containsString(classes, "information-macro") {
+			for _, class := range classes {
+				class = strings.TrimPrefix(class, "aui-message-")
+				if _, ok := panelKinds[class]; ok {
+					return i, class
+				}
+			}
+			return i, "info"
+		}
+		offset = i + end + 1
+	}
+}
+
+// matchingDivClose returns the index of the </div> closing a <div> whose
+// content starts at from, or -1 if it isn't closed.
+func matchingDivClose(s string, from int) int {
+	depth := 1
+	for _, loc := range divTagPattern.FindAllStringIndex(s[from:], -1) {
+		if strings.HasPrefix(s[from+loc[0]:], "</") {
+			depth--
+		} else {
+			depth++
+		}
+		if depth == 0 {
+			return from + loc[0]
+		}
+	}
+	return -1
+}
+
+// renderPanels turns the panels marked by markPanels into blockquotes led by
+// a bold line with the panel's label and title, or with --callouts into
+// GitHub alerts such as "> [!WARNING]". Blank lines at the start and end of
+// a panel are dropped.
+func renderPanels(s string, config Config) string {
+	if !strings.Contains(s, "\x00PANEL:") {
+		return s
+	}
+
+	var sb strings.Builder
+	depth := 0
+	started := false // the current panel has written a line
+	blank := false   // a blank line is due before the next one in the panel
+
+	write := func(line string) {
+		if blank {
+			sb.WriteString(strings.TrimRight(strings.Repeat("> ", depth), " ") + "\n")
+			blank = false
+		}
+		sb.WriteString(strings.TrimRight(strings.Repeat("> ", depth)+line, " ") + "\n")
+	}
+
+	for _, line := range strings.Split(s, "\n") {
+		if marker, ok := strings.CutPrefix(line, "\x00PANEL:"); ok {
+			kind, title, _ := strings.Cut(strings.TrimSuffix(marker, "\x00"), ":")
+			lead := panelLead(kind, title, config)
+			if depth > 0 && started {
+				// Separate a nested panel from what precedes it
+				sb.WriteString(strings.TrimRight(strings.Repeat("> ", depth), " ") + "\n")
+			}
+			depth, blank = depth+1, false
+			for _, l := range lead {
+				write(l)
+			}
+			// A bold lead is a paragraph of its own; an alert marker isn't
+			blank = len(lead) > 0 && !strings.HasPrefix(lead[len(lead)-1], "[!")
+			started = blank
+			continue
+		}
+
+		switch {
+		case line == "\x00/PANEL\x00" && depth > 0:
+			depth--
+			started, blank = true, false
+		case depth == 0:
+			sb.WriteString(line + "\n")
+		case strings.TrimSpace(line) == "":
+			blank = started
+		default:
+			write(line)
+			started = true
+		}
+	}
+
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// panelLead returns the lines a panel's blockquote starts with.
+func panelLead(kind, title string, config Config) []string {
+	info, known := panelKinds[kind]
+	if config.callouts && known {
+		lead := []string{"[!" + info[1] + "]"}
+		if title != "" {
+			lead = append(lead, "**"+title+"**")
+		}
+		return lead
+	}
+
+	switch {
+	case known && title != "":
+		return []string{fmt.Sprintf("**%s: %s**", config.label(info[0]), title)}
+	case known:
+		return []string{fmt.Sprintf("**%s**", config.label(info[0]))}
+	case title != "":
+		return []string{"**" + title + "**"}
+	}
+	return nil
+}
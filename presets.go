@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sectionIDs are the sections a preset can list, in the default order.
+var sectionIDs = []string{
+	"overview", "dates", "timeline", "recent_activity", "environment", "details",
+	"related_issues", "dependencies", "related_links", "comments",
+	"time_tracking", "work_log", "custom_fields", "audit_description",
+	"attachments",
+}
+
+// builtinPresets are the sections shown for common issue types, in order.
+// Sections a preset doesn't list are left out.
+var builtinPresets = map[string][]string{
+	"bug": {
+		"overview", "environment", "details", "custom_fields", "attachments",
+		"comments", "related_issues", "related_links", "dates", "timeline",
+		"recent_activity",
+	},
+	"story": {
+		"overview", "details", "related_issues", "custom_fields", "related_links",
+		"comments", "attachments", "dates", "recent_activity",
+	},
+	"epic": {
+		"overview", "details", "related_issues", "dependencies", "related_links",
+		"time_tracking", "work_log", "timeline", "comments", "custom_fields",
+		"dates",
+	},
+}
+
+// loadPresets reads a JSON object mapping preset names to lists of section
+// ids and returns the built-in presets with those added or replaced.
+func loadPresets(path string) (map[string][]string, error) {
+	presets := make(map[string][]string, len(builtinPresets))
+	for name, sections := range builtinPresets {
+		presets[name] = sections
+	}
+	if path == "" {
+		return presets, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read presets file: %w", err)
+	}
+
+	var custom map[string][]string
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("failed to parse presets file: %w", err)
+	}
+
+	for name, sections := range custom {
+		for _, id := range sections {
+			if !containsString(sectionIDs, id) {
+				return nil, fmt.Errorf("unknown section %q in preset %q (expected one of %s)", id, name, strings.Join(sectionIDs, ", "))
+			}
+		}
+		presets[strings.ToLower(name)] = sections
+	}
+
+	return presets, nil
+}
+
+// itemPreset returns the sections --preset selects for item, or nil to keep
+// the default layout. With --preset auto the preset is named after the issue
+// type, and types without one keep the default.
+func itemPreset(item Item, config Config) []string {
+	name := strings.ToLower(config.preset)
+	if name == "auto" {
+		name = strings.ToLower(strings.TrimSpace(item.Type.Value))
+	}
+	return config.presets[name]
+}
+
+// applyPreset returns the sections listed in preset, in its order.
+func applyPreset(sections []section, preset []string) []section {
+	var ordered []section
+	for _, id := range preset {
+		for _, sec := range sections {
+			if sec.id == id {
+				ordered = append(ordered, sec)
+			}
+		}
+	}
+	return ordered
+}
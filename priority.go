@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// priorityRanks orders JIRA's standard priority names, most urgent first.
+// --priority-order replaces it.
+var priorityRanks = map[string]int{
+	"blocker":  1,
+	"highest":  1,
+	"critical": 2,
+	"high":     2,
+	"major":    3,
+	"medium":   3,
+	"minor":    4,
+	"low":      4,
+	"trivial":  5,
+	"lowest":   5,
+}
+
+// parsePriorityOrder turns a --priority-order list, most urgent first, into
+// ranks keyed by lowercased name.
+func parsePriorityOrder(order []string) (map[string]int, error) {
+	ranks := make(map[string]int, len(order))
+	for i, name := range order {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			return nil, fmt.Errorf("invalid --priority-order: empty priority name")
+		}
+		if _, ok := ranks[name]; ok {
+			return nil, fmt.Errorf("invalid --priority-order: %q listed twice", name)
+		}
+		ranks[name] = i + 1
+	}
+	return ranks, nil
+}
+
+// knownPriorityRank returns the rank of a priority name in the --priority-order
+// list, or the built-in ranking when none was given.
+func knownPriorityRank(name string, config Config) (int, bool) {
+	ranks := config.priorityRanks
+	if ranks == nil {
+		ranks = priorityRanks
+	}
+	rank, ok := ranks[strings.ToLower(strings.TrimSpace(name))]
+	return rank, ok
+}
+
+// priorityRank returns the urgency of a priority, lower being more urgent.
+// Unknown names fall back to the priority id, which JIRA numbers from most
+// urgent, unless --priority-order is in use; priorities with neither sort
+// last.
+func priorityRank(p Priority, config Config) int {
+	if rank, ok := knownPriorityRank(p.Value, config); ok {
+		return rank
+	}
+	if config.priorityRanks == nil {
+		if id, err := strconv.Atoi(strings.TrimSpace(p.ID)); err == nil {
+			return id
+		}
+	}
+	return 1 << 30
+}
+
+// priorityAtLeast reports whether p passes the --min-priority filter. Items
+// whose priority isn't in the ranking are kept, since they can't be compared.
+func priorityAtLeast(p Priority, config Config) bool {
+	if config.minPriority == "" {
+		return true
+	}
+	rank, ok := knownPriorityRank(p.Value, config)
+	if !ok {
+		return true
+	}
+	min, _ := knownPriorityRank(config.minPriority, config)
+	return rank <= min
+}
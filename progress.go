@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// progressInterval is the minimum time between two --progress updates.
+const progressInterval = 250 * time.Millisecond
+
+// progress reports how many input files have been processed. It is safe for
+// concurrent use.
+type progress struct {
+	mu    sync.Mutex
+	w     io.Writer
+	tty   bool
+	total int
+	done  int
+	start time.Time
+	last  time.Time
+}
+
+// newProgress returns a reporter for total files writing to stderr. On a
+// terminal the line is updated in place; otherwise each update is a new line.
+func newProgress(total int) *progress {
+	return &progress{w: os.Stderr, tty: isTerminal(os.Stderr), total: total, start: time.Now()}
+}
+
+// increment records one finished file and prints an update if enough time
+// has passed since the last one, or if it was the last file.
+func (p *progress) increment() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done++
+	now := time.Now()
+	if p.done < p.total && now.Sub(p.last) < progressInterval {
+		return
+	}
+	p.last = now
+
+	line := fmt.Sprintf("processed %d/%d (%d%%)", p.done, p.total, p.done*100/p.total)
+	if p.done < p.total {
+		elapsed := now.Sub(p.start)
+		remaining := elapsed / time.Duration(p.done) * time.Duration(p.total-p.done)
+		line += fmt.Sprintf(", ETA %s", remaining.Round(time.Second))
+	}
+
+	if p.tty {
+		fmt.Fprintf(p.w, "\r%s\x1b[K", line)
+	} else {
+		fmt.Fprintln(p.w, line)
+	}
+}
+
+// finish ends the in-place progress line so later output starts on a fresh
+// line.
+func (p *progress) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tty && !p.last.IsZero() {
+		fmt.Fprintln(p.w)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// stdinReader is shared by all prompts so that answers typed ahead aren't
+// lost between them.
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// promptMu keeps concurrent prompts from interleaving.
+var promptMu sync.Mutex
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// mayOverwrite reports whether the existing file at path may be replaced:
+// always with --force or --assume-yes, after asking when run interactively,
+// and never otherwise.
+func mayOverwrite(path string, config Config) bool {
+	if config.force || config.assumeYes {
+		return true
+	}
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return false
+	}
+
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	fmt.Printf("overwrite %s? [y/N] ", path)
+	answer, _ := stdinReader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// redactedText replaces matches of a --redact-pattern without its own
+// replacement.
+const redactedText = "[REDACTED]"
+
+// redaction is one compiled --redact-pattern.
+type redaction struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// parseRedactions compiles --redact-pattern values. Each is a regular
+// expression, optionally followed by "=>" and a replacement, which may refer
+// to submatches as $1.
+func parseRedactions(specs []string) ([]redaction, error) {
+	var redactions []redaction
+	for _, spec := range specs {
+		expr, replacement := spec, redactedText
+		if i := strings.LastIndex(spec, "=>"); i != -1 {
+			expr, replacement = spec[:i], spec[i+len("=>"):]
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --redact-pattern %q: %w", expr, err)
+		}
+		redactions = append(redactions, redaction{re, replacement})
+	}
+	return redactions, nil
+}
+
+// redact applies every redaction to md and returns the result and the number
+// of matches replaced.
+func redact(md string, redactions []redaction) (string, int) {
+	count := 0
+	for _, r := range redactions {
+		count += len(r.pattern.FindAllStringIndex(md, -1))
+		md = r.pattern.ReplaceAllString(md, r.replacement)
+	}
+	return md, count
+}
+
+// redacted returns s with the --redact-pattern redactions applied, for the
+// single values written outside the documents, such as index and CSV cells.
+func redacted(s string, config Config) string {
+	s, _ = redact(s, config.redactions)
+	return s
+}
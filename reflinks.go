@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// referenceLinks rewrites every inline link [text](url) in md as a reference
+// link [text][n] and appends the numbered definitions at the end of the
+// document. Links sharing a URL share a number. Images, code blocks and code
+// spans are left alone.
+func referenceLinks(md string) string {
+	numbers := make(map[string]int)
+	var urls []string
+
+	out := mapOutsideFences(md, func(line string) string {
+		return referenceLinksInLine(line, func(url string) int {
+			if n, ok := numbers[url]; ok {
+				return n
+			}
+			urls = append(urls, url)
+			numbers[url] = len(urls)
+			return len(urls)
+		})
+	})
+
+	if len(urls) == 0 {
+		return md
+	}
+
+	out = strings.TrimRight(out, "\n")
+	var sb strings.Builder
+	sb.WriteString(out)
+	sb.WriteString("\n\n")
+	for i, url := range urls {
+		fmt.Fprintf(&sb, "[%d]: %s\n", i+1, url)
+	}
+	return sb.String()
+}
+
+// referenceLinksInLine rewrites the inline links of a single line, using
+// number to look up the reference number for a URL.
+func referenceLinksInLine(line string, number func(string) int) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(line); {
+		switch line[i] {
+		case '`':
+			// Copy code spans verbatim
+			run := 1
+			for i+run < len(line) && line[i+run] == '`' {
+				run++
+			}
+			end := strings.Index(line[i+run:], line[i:i+run])
+			if end == -1 {
+				sb.WriteString(line[i:])
+				return sb.String()
+			}
+			end += i + 2*run
+			sb.WriteString(line[i:end])
+			i = end
+			continue
+		case '[':
+			if i > 0 && line[i-1] == '!' {
+				break
+			}
+			textEnd := closingBracket(line, i)
+			if textEnd == -1 || textEnd+1 >= len(line) || line[textEnd+1] != '(' {
+				break
+			}
+			urlEnd := strings.IndexByte(line[textEnd+2:], ')')
+			if urlEnd == -1 {
+				break
+			}
+			url := line[textEnd+2 : textEnd+2+urlEnd]
+			if url == "" || strings.ContainsAny(url, " \t") {
+				break
+			}
+			fmt.Fprintf(&sb, "%s[%d]", line[i:textEnd+1], number(url))
+			i = textEnd + 2 + urlEnd + 1
+			continue
+		}
+		sb.WriteByte(line[i])
+		i++
+	}
+
+	return sb.String()
+}
+
+// closingBracket returns the index of the "]" matching the "[" at open, or -1.
+func closingBracket(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
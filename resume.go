@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// resumeState records the inputs converted successfully by earlier --resume
+// runs, keyed by absolute path, with the SHA-256 of their content and what
+// converting them produced.
+type resumeState struct {
+	Version int                      `json:"version"`
+	Inputs  map[string]string        `json:"inputs"`
+	Results map[string]resumedResult `json:"results,omitempty"`
+
+	mu sync.Mutex
+}
+
+// resumedResult is what converting an input contributed to its run: the
+// files written, for the --manifest, and the items, for the --index and
+// --csv. A later run that skips the input lists them again.
+type resumedResult struct {
+	Outputs []string      `json:"outputs,omitempty"`
+	Items   []resumedItem `json:"items,omitempty"`
+}
+
+// resumedItem is the part of a converted item the --index and --csv show or
+// sort by. Summary holds the summary as itemSummary resolved it.
+type resumedItem struct {
+	Key            Key            `json:"key"`
+	Summary        string         `json:"summary"`
+	Type           TypeField      `json:"type"`
+	Priority       Priority       `json:"priority"`
+	Status         Status         `json:"status"`
+	StatusCategory StatusCategory `json:"statusCategory"`
+	Assignee       User           `json:"assignee"`
+	Reporter       User           `json:"reporter"`
+	Created        string         `json:"created"`
+	Updated        string         `json:"updated"`
+	Votes          string         `json:"votes"`
+	Path           string         `json:"path"`
+	Pos            int            `json:"pos"`
+}
+
+// loadResumeState reads the --state-file. A missing file is an empty state.
+func loadResumeState(path string) (*resumeState, error) {
+	state := &resumeState{Version: 1, Inputs: make(map[string]string), Results: make(map[string]resumedResult)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	if state.Inputs == nil {
+		state.Inputs = make(map[string]string)
+	}
+	if state.Results == nil {
+		state.Results = make(map[string]resumedResult)
+	}
+
+	return state, nil
+}
+
+// processResumable runs processFile on input, unless --resume finds it
+// unchanged since an earlier run converted it, in which case what that run
+// recorded for it goes back into the index, CSV and manifest. Once converted,
+// the input is recorded and the state file saved right away, so an
+// interrupted run loses nothing. resume is nil without --resume.
+func processResumable(input string, config Config, state *runState, resume *resumeState) error {
+	if resume == nil || input == "-" {
+		// Standard input can't be read twice, nor recognized next time
+		return processFile(input, config, state)
+	}
+
+	same, key, hash, err := resume.unchanged(input)
+	if err != nil {
+		return err
+	}
+	if same {
+		if config.verbose {
+			fmt.Fprintf(os.Stderr, "Skipping %s (unchanged since last run)\n", input)
+		}
+		resume.mu.Lock()
+		result := resume.Results[key]
+		resume.mu.Unlock()
+		restoreResult(result, input, config, state)
+		return nil
+	}
+
+	if err := processFile(input, config, state); err != nil {
+		return err
+	}
+	if config.dryRun {
+		return nil
+	}
+
+	outputs, entries := state.inputResults(input, inputPosition(input, config))
+	result := resumedResult{Outputs: outputs}
+	for _, entry := range entries {
+		result.Items = append(result.Items, newResumedItem(entry, config))
+	}
+
+	resume.mu.Lock()
+	defer resume.mu.Unlock()
+	resume.Inputs[key] = hash
+	resume.Results[key] = result
+	return resume.save(config.stateFile)
+}
+
+// restoreResult adds what an earlier run recorded for a skipped input to
+// this run. Files that have since been removed are left out of the manifest.
+func restoreResult(result resumedResult, input string, config Config, state *runState) {
+	for _, path := range result.Outputs {
+		if _, err := os.Stat(path); err == nil {
+			state.addOutput(input, path)
+		}
+	}
+	for _, item := range result.Items {
+		state.addIndexEntry(item.entry(inputPosition(input, config)))
+	}
+}
+
+func newResumedItem(entry indexEntry, config Config) resumedItem {
+	item := entry.item
+	return resumedItem{
+		Key:            item.Key,
+		Summary:        html.EscapeString(itemSummary(item, config)),
+		Type:           item.Type,
+		Priority:       item.Priority,
+		Status:         item.Status,
+		StatusCategory: item.StatusCategory,
+		Assignee:       item.Assignee,
+		Reporter:       item.Reporter,
+		Created:        item.Created,
+		Updated:        item.Updated,
+		Votes:          item.Votes,
+		Path:           entry.path,
+		Pos:            entry.pos,
+	}
+}
+
+// entry turns r back into an index entry of the input at position input.
+func (r resumedItem) entry(input int) indexEntry {
+	item := Item{
+		Key:            r.Key,
+		Summary:        r.Summary,
+		Type:           r.Type,
+		Priority:       r.Priority,
+		Status:         r.Status,
+		StatusCategory: r.StatusCategory,
+		Assignee:       r.Assignee,
+		Reporter:       r.Reporter,
+		Created:        r.Created,
+		Updated:        r.Updated,
+		Votes:          r.Votes,
+	}
+	return indexEntry{item, r.Path, input, r.Pos}
+}
+
+// save writes the state to path, replacing the old file only once the new
+// one is complete so an interrupted run can't corrupt it.
+func (s *resumeState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return nil
+}
+
+// unchanged reports whether input was converted by an earlier run and has
+// the same content now. Inputs recorded without their results, by a version
+// that didn't keep them, count as changed so they are listed again. It also returns the input's key and current hash for
+// recording it once converted.
+func (s *resumeState) unchanged(input string) (bool, string, string, error) {
+	key, err := filepath.Abs(input)
+	if err != nil {
+		key = filepath.Clean(input)
+	}
+
+	f, err := os.Open(input)
+	if err != nil {
+		return false, key, "", fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, key, "", fmt.Errorf("failed to read file: %w", err)
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, recorded := s.Results[key]
+	return recorded && s.Inputs[key] == hash, key, hash, nil
+}
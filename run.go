@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// runState collects what has been produced across all input files in a
+// single invocation. It is shared by the --jobs workers, so its fields are
+// only touched under mu.
+type runState struct {
+	mu      sync.Mutex
+	outputs []string
+	claimed map[string]bool
+	index   []indexEntry
+
+	// planned holds the output file planOutputs chose for each item of each
+	// input file, "" for items it didn't plan
+	planned map[string][]string
+
+	// inputOutputs holds the files written for each input file, for --resume
+	inputOutputs map[string][]string
+
+	// combined holds the documents of each input file for --combine, to be
+	// written in input order once all files are done
+	combined map[string][]string
+}
+
+// addCombined records the documents converted from inputFile for --combine.
+func (s *runState) addCombined(inputFile string, docs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.combined == nil {
+		s.combined = make(map[string][]string)
+	}
+	s.combined[inputFile] = append(s.combined[inputFile], docs...)
+}
+
+// addOutput records a file written from inputFile for the manifest.
+func (s *runState) addOutput(inputFile, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outputs = append(s.outputs, path)
+	if s.inputOutputs == nil {
+		s.inputOutputs = make(map[string][]string)
+	}
+	s.inputOutputs[inputFile] = append(s.inputOutputs[inputFile], path)
+}
+
+// inputResults returns the files written and the items converted from
+// inputFile, at input position input.
+func (s *runState) inputResults(inputFile string, input int) ([]string, []indexEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []indexEntry
+	for _, entry := range s.index {
+		if entry.input == input {
+			entries = append(entries, entry)
+		}
+	}
+	return append([]string(nil), s.inputOutputs[inputFile]...), entries
+}
+
+// addIndexEntry records a converted item for the index and CSV.
+func (s *runState) addIndexEntry(entry indexEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index = append(s.index, entry)
+}
+
+// plannedOutput returns the output file planOutputs chose for the index-th
+// item of inputFile, if it planned one.
+func (s *runState) plannedOutput(inputFile string, index int) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := s.planned[inputFile]
+	if index >= len(files) || files[index] == "" {
+		return "", false
+	}
+	return files[index], true
+}
+
+// inputPosition returns the position of inputFile among the inputs of the
+// run.
+func inputPosition(inputFile string, config Config) int {
+	for i, f := range config.inputFiles {
+		if f == inputFile {
+			return i
+		}
+	}
+	return len(config.inputFiles)
+}
+
+// claimOutput records path as written by this run. If an earlier item already
+// claimed it, the collision is resolved according to --on-collision: append a
+// numeric suffix, fail, or let the later item overwrite.
+func claimOutput(path, onCollision string, state *runState) (string, error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if state.claimed == nil {
+		state.claimed = make(map[string]bool)
+	}
+
+	key := filepath.Clean(path)
+	if state.claimed[key] {
+		switch onCollision {
+		case "overwrite":
+			return path, nil
+		case "suffix":
+			ext := filepath.Ext(path)
+			base := strings.TrimSuffix(path, ext)
+			for n := 2; ; n++ {
+				candidate := fmt.Sprintf("%s-%d%s", base, n, ext)
+				if !state.claimed[filepath.Clean(candidate)] {
+					path, key = candidate, filepath.Clean(candidate)
+					break
+				}
+			}
+		default:
+			return "", fmt.Errorf("output file %s was already written in this run (use --on-collision suffix|overwrite)", path)
+		}
+	}
+
+	state.claimed[key] = true
+	return path, nil
+}
+
+// processFiles converts every input file, running up to --jobs of them at
+// once. A file that fails is reported on stderr as soon as it does, without
+// stopping the others; the errors are returned once all files are done. With
+// --fail-fast no further files are started after the first failure, though
+// those already being converted are finished.
+func processFiles(config Config, state *runState, prog *progress, resume *resumeState) []error {
+	var (
+		mu       sync.Mutex
+		errs     []error
+		wg       sync.WaitGroup
+		stopOnce sync.Once
+	)
+	stop := make(chan struct{})
+
+	inputs := make(chan string)
+	for n := 0; n < config.jobs && n < len(config.inputFiles); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for inputFile := range inputs {
+				if err := processResumable(inputFile, config, state, resume); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					if prog != nil {
+						// Keep the error off the progress line
+						prog.finish()
+					}
+					fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", inputFile, err)
+					mu.Unlock()
+					if config.failFast {
+						stopOnce.Do(func() { close(stop) })
+					}
+				}
+				if prog != nil {
+					prog.increment()
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for _, inputFile := range config.inputFiles {
+		select {
+		case inputs <- inputFile:
+		case <-stop:
+			break dispatch
+		}
+	}
+	close(inputs)
+	wg.Wait()
+
+	return errs
+}
+
+// writeCombined writes the documents of every input file into the --combine
+// output, or to standard output, in the order the files were given,
+// separated by horizontal rules (or as one JSON array).
+func writeCombined(config Config, state *runState) error {
+	var docs []string
+	for _, inputFile := range config.inputFiles {
+		docs = append(docs, state.combined[inputFile]...)
+		// An input named twice is only included once
+		delete(state.combined, inputFile)
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+
+	out := strings.Join(docs, "\n---\n\n")
+	if config.format == "json" {
+		out = jsonArray(docs)
+	}
+	if config.dryRun {
+		reportDryRun(fmt.Sprintf("%d input file(s)", len(config.inputFiles)), config.output, len(encodeOutput(out, config)), config)
+		return nil
+	}
+	if err := writeDocument(config.output, out, config); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	recordOutput(config.output, config, state)
+	return nil
+}
+
+// writeDocument writes a converted document to path, or with --append adds
+// it to the end of the file, after a horizontal rule if the file isn't empty.
+func writeDocument(path, md string, config Config) error {
+	if !config.append || path == "-" {
+		return writeOutput(path, encodeOutput(md, config), config)
+	}
+
+	info, err := os.Stat(path)
+	if err == nil && info.Size() > 0 {
+		// The file already starts with any byte order mark
+		md = "\n---\n\n" + md
+		config.outputBOM = false
+	}
+
+	mode := config.fileMode
+	if mode == 0 {
+		mode = 0644
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(encodeOutput(md, config)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// planOutputs works out which file each item of every input file will be
+// written to, following the same naming and collision rules as processFile,
+// and claims them in state in input order. processFile then uses those
+// files, so which item gets a collision suffix doesn't depend on which
+// worker gets there first. It returns the files of the selected items by
+// key, so that documents can link to items converted later in the run.
+// Files that can't be read or parsed are left for processFile to report.
+func planOutputs(config Config, state *runState) map[string]string {
+	paths := make(map[string]string)
+	state.planned = make(map[string][]string)
+
+	for _, inputFile := range config.inputFiles {
+		if _, seen := state.planned[inputFile]; seen || inputFile == "-" {
+			// Reading standard input here would leave nothing for
+			// processFile; an input given twice claims again when converted
+			continue
+		}
+		data, err := readInput(inputFile, config)
+		if err != nil {
+			continue
+		}
+		docs, err := parseDocuments(data, config)
+		if err != nil {
+			continue
+		}
+
+		items, _ := documentItems(docs)
+		files := make([]string, len(items))
+		state.planned[inputFile] = files
+		combinedFile := ""
+		for i, item := range items {
+			selected := keySelected(item.Key.Value, config.onlyKeys) && priorityAtLeast(item.Priority, config)
+			if !selected && !config.emitEmptyFile {
+				continue
+			}
+			if config.combine {
+				if selected {
+					paths[item.Key.Value] = config.output
+				}
+				continue
+			}
+			outputFile := combinedFile
+			if outputFile == "" {
+				outputFile, err = claimOutput(outputPath(inputFile, item, i, len(items), config), config.onCollision, state)
+				if err != nil {
+					continue
+				}
+				if concatenates(len(items), config) {
+					combinedFile = outputFile
+				}
+			}
+			files[i] = outputFile
+			if selected {
+				paths[item.Key.Value] = outputFile
+			}
+		}
+	}
+
+	return paths
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	pflag "github.com/spf13/pflag"
+)
+
+// maxRequestBody caps the size of an XML export accepted by POST /convert.
+const maxRequestBody = 64 << 20
+
+// serveOnlyFlags are options that touch the local filesystem or control the
+// process. They may be set when starting the service but not per request.
+var serveOnlyFlags = map[string]bool{
+	"output":             true,
+	"force":              true,
+	"verbose":            true,
+	"baseline":           true,
+	"labels-file":        true,
+	"comments-dir":       true,
+	"manifest":           true,
+	"on-collision":       true,
+	"local-links":        true,
+	"progress":           true,
+	"serve":              true,
+	"doctor":             true,
+	"inline-images":      true,
+	"dump-parsed":        true,
+	"assume-yes":         true,
+	"index":              true,
+	"output-permissions": true,
+	"resume":             true,
+	"state-file":         true,
+	"ndjson":             true,
+	"field-render-map":   true,
+	"attachments-dir":    true,
+	"csv":                true,
+	"check-links":        true,
+	"presets-file":       true,
+	"template":           true,
+	"download-images":    true,
+	"icons-file":         true,
+	"combine":            true,
+	"append":             true,
+	"dry-run":            true,
+	"user-map":           true,
+	"jobs":               true,
+	"version":            true,
+}
+
+// ConvertedItem is the JSON representation of one converted issue.
+type ConvertedItem struct {
+	Key      string `json:"key"`
+	Markdown string `json:"markdown"`
+}
+
+// serve runs the HTTP conversion service until SIGINT or SIGTERM.
+func serve(config Config) error {
+	// Requests may turn on --icons or pick a --preset, so have what they
+	// need loaded up front as well
+	if config.statusIcons == nil {
+		status, priority, err := loadIcons(config.iconsFile)
+		if err != nil {
+			return err
+		}
+		config.statusIcons, config.priorityIcons = status, priority
+	}
+	if config.presets == nil {
+		presets, err := loadPresets(config.presetsFile)
+		if err != nil {
+			return err
+		}
+		config.presets = presets
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/convert", handleConvert(config))
+
+	server := &http.Server{
+		Addr:              config.serve,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ListenAndServe()
+	}()
+	if config.verbose {
+		fmt.Printf("Listening on %s\n", config.serve)
+	}
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	if config.verbose {
+		fmt.Println("Shutting down...")
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// handleConvert converts a POSTed XML export. Query parameters mirror the
+// command-line flags and override the options the service was started with.
+// The response is Markdown, or JSON when the client accepts application/json.
+// With format=json it is an array of the --format json documents instead.
+func handleConvert(base Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		config, err := requestConfig(r, base)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		limit := int64(maxRequestBody)
+		if config.maxFileBytes > 0 && config.maxFileBytes < limit {
+			limit = config.maxFileBytes
+		}
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		if err != nil {
+			http.Error(w, "failed to read body: "+err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		docs, err := parseDocuments(data, config)
+		if err != nil {
+			http.Error(w, "failed to parse XML: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		parsed, channelLinks := documentItems(docs)
+		var items []ConvertedItem
+		var jsonDocs []string
+		for i, item := range parsed {
+			config.channelLink = channelLinks[i]
+			if !keySelected(item.Key.Value, config.onlyKeys) || !priorityAtLeast(item.Priority, config) {
+				continue
+			}
+			if config.dedupeComments {
+				item.Comments.Comment, _ = dedupeComments(item.Comments.Comment)
+			}
+			item.Link = publicURL(item.Link, config)
+			if config.format == "json" {
+				doc, err := itemJSON(item, config)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+					return
+				}
+				jsonDocs = append(jsonDocs, doc)
+				continue
+			}
+			md, err := renderItem(item, channelLinks[i], config)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			items = append(items, ConvertedItem{
+				Key:      item.Key.Value,
+				Markdown: md,
+			})
+		}
+		if len(items) == 0 && len(jsonDocs) == 0 {
+			http.Error(w, "no items found in XML", http.StatusUnprocessableEntity)
+			return
+		}
+
+		if config.format == "json" {
+			// The --format json documents, as one array
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, jsonArray(jsonDocs))
+		} else if strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			enc.Encode(items)
+		} else {
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			for i, item := range items {
+				if i > 0 {
+					io.WriteString(w, "\n---\n\n")
+				}
+				io.WriteString(w, item.Markdown)
+			}
+		}
+
+		if base.verbose {
+			fmt.Printf("Converted %d item(s) for %s\n", len(items)+len(jsonDocs), r.RemoteAddr)
+		}
+	}
+}
+
+// requestConfig builds the options for one request: the flags the service
+// was started with, overridden by the request's query parameters. The files
+// the options refer to can't be changed per request, so those loaded into
+// base at startup are shared rather than read again.
+func requestConfig(r *http.Request, base Config) (Config, error) {
+	config := Config{}
+	fs := pflag.NewFlagSet("convert", pflag.ContinueOnError)
+	defineFlags(fs, &config)
+
+	// Replay the service's own command-line settings as defaults
+	var replayErr error
+	pflag.CommandLine.Visit(func(f *pflag.Flag) {
+		if replayErr == nil {
+			replayErr = setFlag(fs, f.Name, f.Value)
+		}
+	})
+	if replayErr != nil {
+		return config, replayErr
+	}
+
+	for name, values := range r.URL.Query() {
+		if serveOnlyFlags[name] {
+			return config, fmt.Errorf("option %q cannot be set per request", name)
+		}
+		if fs.Lookup(name) == nil {
+			return config, fmt.Errorf("unknown option %q", name)
+		}
+		for _, value := range values {
+			if err := fs.Set(name, value); err != nil {
+				return config, fmt.Errorf("invalid value for %q: %v", name, err)
+			}
+		}
+	}
+
+	if err := resolveConfig(&config); err != nil {
+		return config, err
+	}
+
+	config.labels = base.labels
+	config.users = base.users
+	config.fieldRenders = base.fieldRenders
+	config.tmpl = base.tmpl
+	config.baselineIssues = base.baselineIssues
+	if config.icons {
+		config.statusIcons, config.priorityIcons = base.statusIcons, base.priorityIcons
+	}
+	if config.preset != "" {
+		if _, ok := base.presets[strings.ToLower(config.preset)]; !ok && config.preset != "auto" {
+			return config, fmt.Errorf("unknown --preset %q", config.preset)
+		}
+		config.presets = base.presets
+	}
+
+	return config, nil
+}
+
+// setFlag copies the value of a parsed flag onto the flag of the same name
+// in fs, preserving slice values as a whole.
+func setFlag(fs *pflag.FlagSet, name string, value pflag.Value) error {
+	target := fs.Lookup(name)
+	if target == nil {
+		return nil
+	}
+	if sv, ok := value.(pflag.SliceValue); ok {
+		if tv, ok := target.Value.(pflag.SliceValue); ok {
+			return tv.Replace(sv.GetSlice())
+		}
+	}
+	return fs.Set(name, value.String())
+}
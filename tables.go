@@ -0,0 +1,159 @@
+package main
+
+import (
+	"strings"
+)
+
+// convertHTMLTables converts every <table> in s to a Markdown table. The
+// header row is the first row of <thead> or the first row with <th> cells,
+// moved to the top if needed; tables without either use their first row.
+// Short rows are padded with empty cells. Cell contents are left as HTML for
+// the later inline transforms, with paragraphs and line breaks turned into
+// <br>.
+func convertHTMLTables(s string) string {
+	var sb strings.Builder
+
+	for {
+		start := indexTag(s, "table")
+		if start == -1 {
+			break
+		}
+		end := strings.Index(s[start:], "</table>")
+		if end == -1 {
+			break
+		}
+		end += start
+
+		sb.WriteString(s[:start])
+		sb.WriteString(renderTable(s[start:end]))
+		s = s[end+len("</table>"):]
+	}
+	sb.WriteString(s)
+
+	return sb.String()
+}
+
+// renderTable renders the inside of one <table> element.
+func renderTable(table string) string {
+	var rows [][]string
+	headerRow := -1
+
+	theadEnd := -1
+	if i := indexTag(table, "thead"); i != -1 {
+		if j := strings.Index(table[i:], "</thead>"); j != -1 {
+			theadEnd = i + j
+		}
+	}
+
+	for offset := 0; ; {
+		start := indexTag(table[offset:], "tr")
+		if start == -1 {
+			break
+		}
+		start += offset
+		end := strings.Index(table[start:], "</tr>")
+		if end == -1 {
+			end = len(table)
+		} else {
+			end += start
+		}
+
+		row, hasTH := tableRowCells(table[start:end])
+		if headerRow == -1 && (start < theadEnd || hasTH) {
+			headerRow = len(rows)
+		}
+		rows = append(rows, row)
+		offset = end
+	}
+
+	if len(rows) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	if headerRow == -1 {
+		headerRow = 0
+	}
+	header := rows[headerRow]
+	rows = append(rows[:headerRow:headerRow], rows[headerRow+1:]...)
+
+	var sb strings.Builder
+	sb.WriteString("\n\n")
+	writeTableRow(&sb, header, columns)
+	sb.WriteString("|")
+	for i := 0; i < columns; i++ {
+		sb.WriteString(" --- |")
+	}
+	sb.WriteString("\n")
+	for _, row := range rows {
+		writeTableRow(&sb, row, columns)
+	}
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// tableRowCells returns the contents of the <th> and <td> cells of a row, and
+// whether any of them was a <th>.
+func tableRowCells(row string) ([]string, bool) {
+	var cells []string
+	hasTH := false
+
+	for {
+		th, td := indexTag(row, "th"), indexTag(row, "td")
+		start, name := td, "td"
+		if th != -1 && (td == -1 || th < td) {
+			start, name = th, "th"
+			hasTH = true
+		}
+		if start == -1 {
+			break
+		}
+
+		open := strings.Index(row[start:], ">")
+		if open == -1 {
+			break
+		}
+		open += start + 1
+
+		end := strings.Index(row[open:], "</"+name+">")
+		if end == -1 {
+			end = len(row) - open
+		}
+		cells = append(cells, tableCellHTML(row[open:open+end]))
+
+		row = row[open+end:]
+	}
+
+	return cells, hasTH
+}
+
+// tableCellHTML flattens block markup inside a cell so it fits on one
+// Markdown table row.
+func tableCellHTML(cell string) string {
+	cell = strings.ReplaceAll(cell, "<p>", "")
+	cell = strings.ReplaceAll(cell, "</p>", "\n")
+	cell = strings.ReplaceAll(cell, "<br/>", "\n")
+	cell = strings.ReplaceAll(cell, "<br />", "\n")
+	cell = strings.ReplaceAll(cell, "<br>", "\n")
+	return tableCell(cell)
+}
+
+// writeTableRow writes one Markdown table row, padded to columns cells.
+func writeTableRow(sb *strings.Builder, cells []string, columns int) {
+	sb.WriteString("|")
+	for i := 0; i < columns; i++ {
+		cell := ""
+		if i < len(cells) {
+			cell = cells[i]
+		}
+		sb.WriteString(" " + cell + " |")
+	}
+	sb.WriteString("\n")
+}
@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestConvertHTMLTables(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "th header row",
+			in:   "<table><tbody><tr><th>Name</th><th>Value</th></tr><tr><td>a</td><td>1</td></tr></tbody></table>",
+			want: "\n\n| Name | Value |\n| --- | --- |\n| a | 1 |\n\n",
+		},
+		{
+			name: "thead with td cells",
+			in:   "<table><thead><tr><td>Name</td><td>Value</td></tr></thead><tbody><tr><td>a</td><td>1</td></tr></tbody></table>",
+			want: "\n\n| Name | Value |\n| --- | --- |\n| a | 1 |\n\n",
+		},
+		{
+			name: "th row after data rows",
+			in:   "<table><tr><td>a</td><td>1</td></tr><tr><th>Name</th><th>Value</th></tr></table>",
+			want: "\n\n| Name | Value |\n| --- | --- |\n| a | 1 |\n\n",
+		},
+		{
+			name: "no th",
+			in:   "<table><tr><td>a</td><td>1</td></tr><tr><td>b</td><td>2</td></tr></table>",
+			want: "\n\n| a | 1 |\n| --- | --- |\n| b | 2 |\n\n",
+		},
+		{
+			name: "no th, short rows padded",
+			in:   "<table class=\"confluenceTable\"><tr><td>a</td><td>1</td><td>x</td></tr><tr><td>b</td></tr></table>",
+			want: "\n\n| a | 1 | x |\n| --- | --- | --- |\n| b |  |  |\n\n",
+		},
+		{
+			name: "cells with breaks and pipes",
+			in:   "<table><tr><th>Step</th></tr><tr><td><p>one</p><p>a|b</p></td></tr></table>",
+			want: "\n\n| Step |\n| --- |\n| one<br>a\\|b |\n\n",
+		},
+		{
+			name: "text around the table",
+			in:   "<p>Before</p><table><tr><th>H</th></tr></table><p>After</p>",
+			want: "<p>Before</p>\n\n| H |\n| --- |\n\n<p>After</p>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := convertHTMLTables(tt.in); got != tt.want {
+				t.Errorf("convertHTMLTables(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// loadTemplate parses the --template file. The helper functions are bound to
+// placeholders here so the file can be checked up front; executeTemplate
+// rebinds them to the options of the item being rendered.
+func loadTemplate(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+
+	tmpl, err := template.New(path).Funcs(templateFuncs(Item{}, "", Config{})).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// templateFuncs are the helpers available to a --template, bound to one
+// item and the options it is rendered with.
+func templateFuncs(item Item, channelLink string, config Config) template.FuncMap {
+	return template.FuncMap{
+		"decodeHTML": func(s string) string {
+			return renderBody(s, config)
+		},
+		"description": func() string {
+			description, _ := renderDescription(item, config)
+			return description
+		},
+		"summary": func() string {
+			return itemSummary(item, config)
+		},
+		"label": config.label,
+		"user": func(user User) string {
+			return formatUser(user, config)
+		},
+		"customField": func(name string) string {
+			for _, cf := range item.CustomFields.CustomField {
+				if strings.EqualFold(strings.TrimSpace(cf.CustomFieldName), name) || cf.ID == name {
+					return joinValues(customFieldValues(cf), config)
+				}
+			}
+			return ""
+		},
+		"issueLink": func(key string) string {
+			return issueLink(key, config)
+		},
+		"channelLink": func() string {
+			return channelLink
+		},
+		"join": strings.Join,
+	}
+}
+
+// executeTemplate renders item with the --template, in place of
+// generateMarkdown.
+func executeTemplate(item Item, channelLink string, config Config) (string, error) {
+	tmpl, err := config.tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Funcs(templateFuncs(item, channelLink, config)).Execute(&sb, item); err != nil {
+		return "", fmt.Errorf("failed to execute template for %s: %w", item.Key.Value, err)
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TimeValue is a time tracking field such as <timespent seconds="5400">1 hour,
+// 30 minutes</timespent>.
+type TimeValue struct {
+	Seconds string `xml:"seconds,attr"`
+	Value   string `xml:",chardata"`
+}
+
+// JIRA's default working time, used to read and write durations like "1d 4h".
+const (
+	hoursPerDay = 8
+	daysPerWeek = 5
+)
+
+// timeTrackingSection summarizes the estimates and logged time of item, or
+// returns a section without fields when the export has none. The total
+// logged time is added up from the work log when the export doesn't give it.
+func timeTrackingSection(item Item, config Config) section {
+	sec := section{id: "time_tracking", heading: config.label("time_tracking")}
+	add := func(label string, v TimeValue) {
+		if value := strings.TrimSpace(v.Value); value != "" {
+			sec.fields = append(sec.fields, field{label, value})
+		} else if seconds, err := strconv.Atoi(strings.TrimSpace(v.Seconds)); err == nil {
+			sec.fields = append(sec.fields, field{label, formatDuration(seconds)})
+		}
+	}
+
+	add(config.label("original_estimate"), item.TimeOriginalEstimate)
+	add(config.label("remaining_estimate"), item.TimeEstimate)
+
+	spent := item.TimeSpent
+	if strings.TrimSpace(spent.Value) == "" && strings.TrimSpace(spent.Seconds) == "" {
+		total, counted := 0, false
+		for _, wl := range item.Worklogs.Worklog {
+			if seconds, ok := parseDuration(wl.TimeSpent); ok {
+				total += seconds
+				counted = true
+			}
+		}
+		if counted {
+			spent.Seconds = strconv.Itoa(total)
+		}
+	}
+	add(config.label("time_spent"), spent)
+
+	return sec
+}
+
+// parseDuration reads a JIRA duration such as "1w 2d 3h 30m" or "45m" into
+// seconds.
+func parseDuration(s string) (int, bool) {
+	units := map[byte]int{
+		'w': daysPerWeek * hoursPerDay * 3600,
+		'd': hoursPerDay * 3600,
+		'h': 3600,
+		'm': 60,
+		's': 1,
+	}
+
+	total := 0
+	parts := strings.Fields(s)
+	if len(parts) == 0 {
+		return 0, false
+	}
+	for _, part := range parts {
+		if len(part) < 2 {
+			return 0, false
+		}
+		unit, ok := units[part[len(part)-1]]
+		if !ok {
+			return 0, false
+		}
+		n, err := strconv.ParseFloat(part[:len(part)-1], 64)
+		if err != nil {
+			return 0, false
+		}
+		total += int(n * float64(unit))
+	}
+	return total, true
+}
+
+// formatDuration writes seconds as a JIRA duration such as "1d 4h 30m".
+func formatDuration(seconds int) string {
+	if seconds <= 0 {
+		return "0m"
+	}
+
+	var parts []string
+	for _, u := range []struct {
+		suffix string
+		size   int
+	}{
+		{"w", daysPerWeek * hoursPerDay * 3600},
+		{"d", hoursPerDay * 3600},
+		{"h", 3600},
+		{"m", 60},
+	} {
+		if n := seconds / u.size; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d%s", n, u.suffix))
+			seconds %= u.size
+		}
+	}
+	if len(parts) == 0 {
+		return "0m"
+	}
+	return strings.Join(parts, " ")
+}
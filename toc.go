@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// slugger assigns GitHub-style anchors to headings, numbering repeats as
+// GitHub does ("notes", "notes-1", ...).
+type slugger map[string]int
+
+// slug returns the anchor for a heading's text: lowercased, with
+// punctuation removed and spaces turned into hyphens.
+func (s slugger) slug(text string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case r == ' ':
+			sb.WriteRune('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			sb.WriteRune(r)
+		}
+	}
+
+	slug := sb.String()
+	n, seen := s[slug]
+	s[slug] = n + 1
+	if seen {
+		return fmt.Sprintf("%s-%d", slug, n)
+	}
+	return slug
+}
+
+// tableOfContents returns a Contents section linking to the "##" headings of
+// body, the rendered sections that follow title. Every heading in the
+// document is slugged in order, so anchors repeated by headings in issue
+// bodies are numbered as GitHub numbers them.
+func tableOfContents(title, body string, config Config) string {
+	slugs := slugger{}
+	slugs.slug(title)
+	heading := config.label("contents")
+	slugs.slug(heading)
+
+	var entries []string
+	mapOutsideFences(body, func(line string) string {
+		level := len(line) - len(strings.TrimLeft(line, "#"))
+		if level == 0 || level > 6 || !strings.HasPrefix(line[level:], " ") {
+			return line
+		}
+		text := strings.TrimSpace(line[level:])
+		anchor := slugs.slug(text)
+		if level == 2 {
+			entries = append(entries, fmt.Sprintf("- [%s](#%s)", text, anchor))
+		}
+		return line
+	})
+	if len(entries) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("## %s\n\n%s\n\n", heading, strings.Join(entries, "\n"))
+}
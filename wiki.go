@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var issueKeyPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]+-[0-9]+$`)
+
+var wikiHeadingPattern = regexp.MustCompile(`(?m)^h([1-6])\. (.*)$`)
+
+var wikiMacroPattern = regexp.MustCompile(`\{([a-zA-Z][a-zA-Z0-9_-]*)(?::[^{}\n]*)?\}`)
+
+var wikiListPattern = regexp.MustCompile(`^([*#-]+) (.*)$`)
+
+var wikiMonospacePattern = regexp.MustCompile(`\{\{(.+?)\}\}`)
+
+var wikiBoldPattern = regexp.MustCompile(`(^|[^\w*])\*([^\s*](?:[^*\n]*[^\s*])?)\*($|[^\w*])`)
+
+var htmlMarkupPattern = regexp.MustCompile(`(?i)</?(?:p|br|div|span|a|b|i|em|strong|u|del|code|pre|ul|ol|li|table|tr|td|th|h[1-6]|img|blockquote)(?:\s[^>]*)?/?>`)
+
+// convertWiki converts JIRA wiki markup constructs to Markdown. It is applied
+// by decodeHTML when --input-format is wiki, or auto finds no HTML. Lists go
+// first so their "*" and "#" markers aren't taken for bold text or headings.
+func convertWiki(s string, config Config) string {
+	s = convertWikiLists(s)
+	s = convertWikiHeadings(s, config)
+	s = convertWikiLinks(s, config)
+	s = convertWikiInline(s)
+	return s
+}
+
+// detectMarkup returns the markup a description or comment is written in
+// for --input-format auto: html if it contains any common HTML tag, wiki
+// otherwise.
+func detectMarkup(s string) string {
+	if htmlMarkupPattern.MatchString(s) {
+		return "html"
+	}
+	return "wiki"
+}
+
+// convertWikiLists converts "*", "-" and "#" list items to Markdown bullets
+// and numbered items. Nested items such as "**" or "#*" are indented to the
+// content of their parent item, as CommonMark requires. Numbering restarts
+// after any line that isn't a list item, and a blank line ends each list so
+// the text after it isn't read as part of the last item.
+func convertWikiLists(s string) string {
+	var out []string
+	var counters []int
+	var indents []int // where the content of the last item at each depth starts
+	for _, line := range strings.Split(s, "\n") {
+		m := wikiListPattern.FindStringSubmatch(line)
+		// "-" only starts a list on its own, not nested; "---" is a rule
+		if m == nil || (strings.Contains(m[1], "-") && m[1] != "-") {
+			if counters != nil && strings.TrimSpace(line) != "" {
+				out = append(out, "")
+			}
+			counters, indents = nil, nil
+			out = append(out, line)
+			continue
+		}
+
+		depth := len(m[1])
+		for len(counters) < depth {
+			counters = append(counters, 0)
+		}
+		counters = counters[:depth]
+		for len(indents) < depth-1 {
+			// A level skipped with "**" under nothing nests by two spaces
+			indent := 0
+			if len(indents) > 0 {
+				indent = indents[len(indents)-1]
+			}
+			indents = append(indents, indent+2)
+		}
+		indents = indents[:depth-1]
+
+		marker := "-"
+		if m[1][depth-1] == '#' {
+			counters[depth-1]++
+			marker = fmt.Sprintf("%d.", counters[depth-1])
+		}
+		indent := 0
+		if depth > 1 {
+			indent = indents[depth-2]
+		}
+		out = append(out, strings.Repeat(" ", indent)+marker+" "+m[2])
+		indents = append(indents, indent+len(marker)+1)
+	}
+	return strings.Join(out, "\n")
+}
+
+// convertWikiInline converts {{monospace}} to code spans and *bold* to
+// **bold**. Wiki _italic_ is already Markdown. Text in code spans is left as
+// it is.
+func convertWikiInline(s string) string {
+	var spans []string
+	s = wikiMonospacePattern.ReplaceAllStringFunc(s, func(m string) string {
+		spans = append(spans, renderCodeSpan(wikiMonospacePattern.FindStringSubmatch(m)[1]))
+		return fmt.Sprintf("\x00CODE%d\x00", len(spans)-1)
+	})
+
+	// A match consumes the character after it, which may be the one before
+	// the next bold span, so repeat until nothing changes
+	for {
+		next := wikiBoldPattern.ReplaceAllString(s, "$1**$2**$3")
+		if next == s {
+			break
+		}
+		s = next
+	}
+
+	for i, span := range spans {
+		s = strings.Replace(s, fmt.Sprintf("\x00CODE%d\x00", i), span, 1)
+	}
+	return s
+}
+
+// convertWikiHeadings converts "h1. Title" through "h6. Title" lines to
+// Markdown headings. Like HTML headings they are demoted two levels, so
+// "h1." nests under the generated "##" sections, and then shifted down by
+// --heading-offset levels.
+func convertWikiHeadings(s string, config Config) string {
+	return wikiHeadingPattern.ReplaceAllStringFunc(s, func(line string) string {
+		m := wikiHeadingPattern.FindStringSubmatch(line)
+		return strings.Repeat("#", headingLevel(int(m[1][0]-'0')+2, config)) + " " + strings.TrimSpace(m[2])
+	})
+}
+
+// headingLevel applies --heading-offset to a heading level, keeping it
+// within Markdown's six levels.
+func headingLevel(level int, config Config) int {
+	level += config.headingOffset
+	if level < 1 {
+		return 1
+	}
+	if level > 6 {
+		return 6
+	}
+	return level
+}
+
+// convertWikiLinks converts [text|url] to [text](url) and [url] to <url>.
+// [~user] mentions and [KEY-123] issue references are handed to their own
+// transforms instead of being treated as URLs.
+func convertWikiLinks(s string, config Config) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+
+	for {
+		start := strings.Index(s, "[")
+		if start == -1 {
+			break
+		}
+		end := strings.IndexAny(s[start+1:], "]\n")
+		if end == -1 {
+			break
+		}
+		end += start + 1
+
+		// Not a closed bracket on this line, or already a Markdown link
+		if s[end] != ']' || strings.HasPrefix(s[end+1:], "(") {
+			sb.WriteString(s[:end])
+			s = s[end:]
+			continue
+		}
+
+		inner := s[start+1 : end]
+		sb.WriteString(s[:start])
+		sb.WriteString(convertWikiLink(inner, config))
+		s = s[end+1:]
+	}
+	sb.WriteString(s)
+
+	return sb.String()
+}
+
+func convertWikiLink(inner string, config Config) string {
+	if user, ok := strings.CutPrefix(inner, "~"); ok {
+		return wikiMention(user, config)
+	}
+	if issueKeyPattern.MatchString(inner) {
+		return issueLink(inner, config)
+	}
+
+	if text, target, ok := strings.Cut(inner, "|"); ok {
+		// Drop any trailing "|tooltip"
+		target, _, _ = strings.Cut(target, "|")
+		target = strings.TrimSpace(target)
+		if issueKeyPattern.MatchString(target) {
+			if url := issueURL(target, config); url != "" {
+				return fmt.Sprintf("[%s](%s)", text, url)
+			}
+			return text
+		}
+		if isURL(target) {
+			return fmt.Sprintf("[%s](%s)", text, target)
+		}
+	} else if isURL(strings.TrimSpace(inner)) {
+		return "<" + strings.TrimSpace(inner) + ">"
+	}
+
+	return "[" + inner + "]"
+}
+
+// stripMacros removes the {macro} and {macro:params} delimiters left after
+// every other transform, keeping the content between them, and returns the
+// names of the macros it removed in order of first appearance.
+func stripMacros(s string) (string, []string) {
+	var names []string
+	seen := map[string]bool{}
+
+	s = wikiMacroPattern.ReplaceAllStringFunc(s, func(macro string) string {
+		name := wikiMacroPattern.FindStringSubmatch(macro)[1]
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		return ""
+	})
+
+	return s, names
+}
+
+// wikiMention renders a [~username] mention, with the --user-map name when
+// there is one.
+func wikiMention(user string, config Config) string {
+	return "@" + userName(user, config)
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") ||
+		strings.HasPrefix(s, "ftp://") || strings.HasPrefix(s, "mailto:") ||
+		strings.HasPrefix(s, "file:")
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestConvertWikiHeadings(t *testing.T) {
+	tests := []struct {
+		in     string
+		offset int
+		want   string
+	}{
+		{"h1. Title", 0, "### Title"},
+		{"h2. Title", 0, "#### Title"},
+		{"h3. Title", 0, "##### Title"},
+		{"h4. Title", 0, "###### Title"},
+		{"h5. Title", 0, "###### Title"},
+		{"h6. Title", 0, "###### Title"},
+		{"h1. Title", -2, "# Title"},
+		{"h1. Title", 1, "#### Title"},
+		{"h1.  Padded  ", 0, "### Padded"},
+		{"text\nh2. Mid\nmore", 0, "text\n#### Mid\nmore"},
+		{"h7. Not a heading", 0, "h7. Not a heading"},
+		{"see h1. inline", 0, "see h1. inline"},
+	}
+
+	for _, tt := range tests {
+		config := testConfig(t)
+		config.headingOffset = tt.offset
+		if got := convertWikiHeadings(tt.in, config); got != tt.want {
+			t.Errorf("convertWikiHeadings(%q) with offset %d = %q, want %q", tt.in, tt.offset, got, tt.want)
+		}
+	}
+}